@@ -4,20 +4,31 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/isbn"
 	"github.com/RayMC17/bookclub-api/internal/validator"
 )
 
 func (a *applicationDependencies) createBookHandler(w http.ResponseWriter, r *http.Request) {
 	var incomingData struct {
-		Title           string   `json:"title"`
-		Authors         []string `json:"authors"`
-		ISBN            string   `json:"isbn"`
-		PublicationDate string   `json:"publication_date"`
-		Genre           string   `json:"genre"`
-		Description     string   `json:"description"`
-		AverageRating   float64  `json:"average_rating"`
+		Title           string             `json:"title"`
+		Authors         []string           `json:"authors"`
+		ISBN            string             `json:"isbn"`
+		PublicationDate string             `json:"publication_date"`
+		Genre           string             `json:"genre"`
+		Description     string             `json:"description"`
+		Publisher       string             `json:"publisher"`
+		Language        string             `json:"language"`
+		Pages           *int               `json:"pages"`
+		Format          string             `json:"format"`
+		DurationMinutes *int               `json:"duration_minutes"`
+		OriginalYear    *int               `json:"original_year"`
+		Contributors    []data.Contributor `json:"contributors"`
+		Genres          []string           `json:"genres"`
+		WorkID          int64              `json:"work_id"`
 	}
 	err := a.readJSON(w, r, &incomingData)
 	if err != nil {
@@ -26,27 +37,77 @@ func (a *applicationDependencies) createBookHandler(w http.ResponseWriter, r *ht
 	}
 
 	book := &data.Book{
-		Title:         incomingData.Title,
-		Authors:       incomingData.Authors,
-		ISBN:          incomingData.ISBN,
-		Genre:         incomingData.Genre,
-		Description:   incomingData.Description,
-		AverageRating: incomingData.AverageRating,
+		Title:           incomingData.Title,
+		Authors:         incomingData.Authors,
+		ISBN:            incomingData.ISBN,
+		Genre:           incomingData.Genre,
+		Description:     incomingData.Description,
+		Publisher:       incomingData.Publisher,
+		Language:        data.NormalizeLanguage(incomingData.Language),
+		Pages:           incomingData.Pages,
+		Format:          incomingData.Format,
+		DurationMinutes: incomingData.DurationMinutes,
+		OriginalYear:    incomingData.OriginalYear,
+		WorkID:          incomingData.WorkID,
+	}
+	if incomingData.Contributors != nil {
+		book.SetContributors(incomingData.Contributors)
 	}
 
 	v := validator.New()
+	if incomingData.PublicationDate != "" {
+		book.PublicationDate, err = time.Parse("2006-01-02", incomingData.PublicationDate)
+		if err != nil {
+			v.AddError("publication_date", "must be a date in the format YYYY-MM-DD")
+		}
+	}
+
+	if incomingData.Genres != nil {
+		err = a.setBookGenres(book, incomingData.Genres, v)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// members may send just the ISBN and let the provider fill in the rest
+	err = a.lookupMetadata(book)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	data.ValidateBook(v, book)
 	if !v.Valid() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = a.bookModel.Insert(book)
+	user := a.contextGetUser(r)
+	direct, err := a.publishesDirectly(user.ID)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
 
+	// books from regular members wait in the moderation queue
+	if !direct {
+		a.submitBook(w, r, user, book)
+		return
+	}
+
+	err = a.bookModel.Insert(book, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownWork):
+			v.AddError("work_id", "does not exist")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/api/v1/books/%d", book.ID))
 	data := envelope{"book": book}
@@ -56,13 +117,20 @@ func (a *applicationDependencies) createBookHandler(w http.ResponseWriter, r *ht
 	}
 }
 
-func (a *applicationDependencies) getBookHandler(w http.ResponseWriter, r *http.Request) {
+// enrichBookHandler fills the empty fields of an existing book from the
+// metadata provider.
+func (a *applicationDependencies) enrichBookHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
 	if err != nil {
 		a.notFoundResponse(w, r)
 		return
 	}
 
+	if a.metadata == nil {
+		a.metadataUnavailableResponse(w, r)
+		return
+	}
+
 	book, err := a.bookModel.Get(id)
 	if err != nil {
 		switch {
@@ -74,84 +142,292 @@ func (a *applicationDependencies) getBookHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	data := envelope{"book": book}
+	meta, err := a.metadata.LookupISBN(book.ISBN)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrMetadataNotFound):
+			a.errorResponseJSON(w, r, http.StatusNotFound, "no metadata was found for this book's isbn")
+		case errors.Is(err, data.ErrMetadataUnavailable):
+			a.metadataUnavailableResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	filled := book.Enrich(meta)
+	if len(filled) > 0 {
+		v := validator.New()
+		data.ValidateBook(v, book)
+		if !v.Valid() {
+			a.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		err = a.bookModel.Update(book, a.contextGetUser(r).ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConfilct):
+				a.bookConflictResponse(w, r, id)
+			default:
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	data := envelope{"book": book, "filled_fields": filled}
 	err = a.writeJSON(w, http.StatusOK, data, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
-func (a *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *http.Request) {
+// setBookGenres replaces the genres of a book with the genres named by slugs.
+// Unknown slugs are reported through v. A book without a free-text genre
+// takes the name of its first genre.
+func (a *applicationDependencies) setBookGenres(book *data.Book, slugs []string, v *validator.Validator) error {
+	genres, err := a.genreModel.GetBySlugs(slugs)
+	if err != nil {
+		if errors.Is(err, data.ErrUnknownGenreSlugs) {
+			v.AddError("genres", "must only contain existing genre slugs")
+			return nil
+		}
+		return err
+	}
+
+	book.Genres = genres
+	if book.Genre == "" && len(genres) > 0 {
+		book.Genre = data.GenreLabel(genres[0].Name)
+	}
+	return nil
+}
+
+// lookupMetadata fills the empty fields of book from the metadata provider.
+// Nothing happens when no provider is configured or ready, the ISBN is
+// invalid or the provider does not know the book.
+func (a *applicationDependencies) lookupMetadata(book *data.Book) error {
+	if a.metadata == nil {
+		return nil
+	}
+
+	// an invalid ISBN is reported by ValidateBook
+	canonical, err := isbn.Parse(book.ISBN)
+	if err != nil {
+		return nil
+	}
+
+	meta, err := a.metadata.LookupISBN(canonical)
+	if err != nil {
+		if errors.Is(err, data.ErrMetadataNotFound) || errors.Is(err, data.ErrMetadataUnavailable) {
+			return nil
+		}
+		return err
+	}
+
+	book.Enrich(meta)
+	return nil
+}
+
+func (a *applicationDependencies) getBookHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
 	if err != nil {
 		a.notFoundResponse(w, r)
 		return
 	}
 
+	v := validator.New()
+	vw := a.readView(r.URL.Query(), bookShape, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	book, err := a.bookModel.Get(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			a.notFoundResponse(w, r)
+			a.bookNotFoundResponse(w, r, id)
 		default:
 			a.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	var incomingData struct {
-		Title         *string   `json:"title"`
-		Authors       *[]string `json:"authors"`
-		ISBN          *string   `json:"isbn"`
-		Genre         *string   `json:"genre"`
-		Description   *string   `json:"description"`
-		AverageRating *float64  `json:"average_rating"`
-	}
-	err = a.readJSON(w, r, &incomingData)
+	shaped, err := a.viewBook(vw, book)
 	if err != nil {
-		a.badRequestResponse(w, r, err)
+		a.serverErrorResponse(w, r, err)
 		return
 	}
 
-	if incomingData.Title != nil {
-		book.Title = *incomingData.Title
+	// ratings and series change without a new version, so the tag is
+	// taken from the response rather than the version alone
+	data := envelope{"book": shaped}
+	tag, err := representationETag(book.Version, data)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
 	}
-	if incomingData.Authors != nil {
-		book.Authors = *incomingData.Authors
+	if notModified(w, r, tag) {
+		return
 	}
-	if incomingData.ISBN != nil {
-		book.ISBN = *incomingData.ISBN
+
+	headers := make(http.Header)
+	headers.Set("ETag", tag)
+	err = a.writeJSON(w, http.StatusOK, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
 	}
-	if incomingData.Genre != nil {
-		book.Genre = *incomingData.Genre
+}
+
+func (a *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
 	}
-	if incomingData.Description != nil {
-		book.Description = *incomingData.Description
+
+	book, err := a.bookModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !ifMatch(r, book.Version) {
+		a.versionMismatchResponse(w, r, http.StatusPreconditionFailed, "book", book, book.Version)
+		return
 	}
-	if incomingData.AverageRating != nil {
-		book.AverageRating = *incomingData.AverageRating
+
+	var incomingData bookEdits
+	err = a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
 	}
 
 	v := validator.New()
+	err = a.applyBookEdits(book, incomingData, v)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
 	data.ValidateBook(v, book)
 	if !v.Valid() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = a.bookModel.Update(book)
+	err = a.bookModel.Update(book, a.contextGetUser(r).ID)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConfilct):
+			a.bookConflictResponse(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	headers := make(http.Header)
+	headers.Set("ETag", etag(book.Version))
 	data := envelope{"book": book}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	err = a.writeJSON(w, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
+// bookEdits are the fields of a book a client may change. Fields left out
+// of the request stay nil and keep their value.
+type bookEdits struct {
+	Title           *string             `json:"title"`
+	Authors         *[]string           `json:"authors"`
+	ISBN            *string             `json:"isbn"`
+	Genre           *string             `json:"genre"`
+	Description     *string             `json:"description"`
+	Publisher       *string             `json:"publisher"`
+	Language        *string             `json:"language"`
+	Pages           *int                `json:"pages"`
+	Format          *string             `json:"format"`
+	DurationMinutes *int                `json:"duration_minutes"`
+	OriginalYear    *int                `json:"original_year"`
+	Contributors    *[]data.Contributor `json:"contributors"`
+	Genres          *[]string           `json:"genres"`
+}
+
+// applyBookEdits copies the edits onto book. Unknown genre slugs are
+// reported through v.
+func (a *applicationDependencies) applyBookEdits(book *data.Book, edits bookEdits, v *validator.Validator) error {
+	if edits.Title != nil {
+		book.Title = *edits.Title
+	}
+	if edits.Authors != nil {
+		book.Authors = *edits.Authors
+	}
+	if edits.ISBN != nil {
+		book.ISBN = *edits.ISBN
+	}
+	if edits.Genre != nil && *edits.Genre != book.Genre {
+		book.Genre = *edits.Genre
+		// matched against the taxonomy again when saved, unless genres are
+		// given below
+		book.Genres = nil
+	}
+	if edits.Description != nil {
+		book.Description = *edits.Description
+	}
+	if edits.Publisher != nil {
+		book.Publisher = *edits.Publisher
+	}
+	if edits.Language != nil {
+		book.Language = data.NormalizeLanguage(*edits.Language)
+	}
+	if edits.Pages != nil {
+		book.Pages = edits.Pages
+	}
+	if edits.Format != nil {
+		book.Format = *edits.Format
+		// a duration only makes sense for an audiobook
+		if book.Format != data.FormatAudiobook {
+			book.DurationMinutes = nil
+		}
+	}
+	if edits.DurationMinutes != nil {
+		book.DurationMinutes = edits.DurationMinutes
+	}
+	if edits.OriginalYear != nil {
+		book.OriginalYear = edits.OriginalYear
+	}
+	if edits.Contributors != nil {
+		book.SetContributors(*edits.Contributors)
+	}
+	if edits.Genres != nil {
+		return a.setBookGenres(book, *edits.Genres, v)
+	}
+	return nil
+}
+
+// bookConflictResponse reloads a book that changed while it was being
+// updated and sends the current version back with a 409.
+func (a *applicationDependencies) bookConflictResponse(w http.ResponseWriter, r *http.Request, id int) {
+	current, err := a.bookModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.versionMismatchResponse(w, r, http.StatusConflict, "book", current, current.Version)
+}
+
 func (a *applicationDependencies) deleteBookHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
 	if err != nil {
@@ -159,7 +435,7 @@ func (a *applicationDependencies) deleteBookHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	err = a.bookModel.Delete(id)
+	err = a.bookModel.Delete(id, a.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -179,16 +455,25 @@ func (a *applicationDependencies) deleteBookHandler(w http.ResponseWriter, r *ht
 
 func (a *applicationDependencies) listBooksHandler(w http.ResponseWriter, r *http.Request) {
 	var queryParametersData struct {
+		ISBN string
 		data.Filters
 	}
 	queryParameters := r.URL.Query()
 
 	v := validator.New()
 
+	// either ISBN form is accepted, lookups use the canonical ISBN-13
+	queryParametersData.ISBN = a.getSingleQueryParameter(queryParameters, "isbn", "")
+	if queryParametersData.ISBN != "" {
+		queryParametersData.ISBN = data.ValidateISBN(v, queryParametersData.ISBN)
+	}
+
 	queryParametersData.Filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
 	queryParametersData.Filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
 	queryParametersData.Filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "id")
 	queryParametersData.Filters.SortSafelist = []string{"id", "title", "-id", "-title"}
+	a.readCursor(queryParameters, &queryParametersData.Filters, v)
+	vw := a.readView(queryParameters, bookShape, v)
 
 	// Check if our filters are valid
 	data.ValidateFilters(v, &queryParametersData.Filters)
@@ -198,6 +483,7 @@ func (a *applicationDependencies) listBooksHandler(w http.ResponseWriter, r *htt
 	}
 
 	books, metadata, err := a.bookModel.GetAll(
+		queryParametersData.ISBN,
 		queryParametersData.Filters,
 	)
 	if err != nil {
@@ -205,8 +491,14 @@ func (a *applicationDependencies) listBooksHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
+	shaped, err := a.viewBooks(vw, books)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	responseData := envelope{
-		"books":     books,
+		"books":     shaped,
 		"@metadata": metadata,
 	}
 	err = a.writeJSON(w, http.StatusOK, responseData, nil)
@@ -217,22 +509,27 @@ func (a *applicationDependencies) listBooksHandler(w http.ResponseWriter, r *htt
 
 func (a *applicationDependencies) searchBookHandler(w http.ResponseWriter, r *http.Request) {
 	var queryParametersData struct {
-		Title  string
-		Author string
-		Genre  string
+		data.BookSearch
 		data.Filters
 	}
 	queryParameters := r.URL.Query()
 	// Load the query parameters into our struct
-	queryParametersData.Title = a.getSingleQueryParameter(queryParameters, "title", "")
-	queryParametersData.Genre = a.getSingleQueryParameter(queryParameters, "genre", "")
-	queryParametersData.Author = a.getSingleQueryParameter(queryParameters, "author", "")
 	v := validator.New()
+	queryParametersData.BookSearch = a.readBookSearch(queryParameters, v)
+	withFacets := a.getSingleBooleanParameter(queryParameters, "facets", false, v)
+
+	// results are ordered by relevance unless the client asks otherwise
+	defaultSort := "id"
+	if queryParametersData.Query != "" {
+		defaultSort = "-rank"
+	}
 
 	queryParametersData.Filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
 	queryParametersData.Filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
-	queryParametersData.Filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "id")
-	queryParametersData.Filters.SortSafelist = []string{"id", "title", "genre", "author", "-id", "-title", "-genre", "-author"}
+	queryParametersData.Filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", defaultSort)
+	queryParametersData.Filters.SortSafelist = []string{"id", "title", "genre", "author", "rank", "-id", "-title", "-genre", "-author", "-rank"}
+	a.readCursor(queryParameters, &queryParametersData.Filters, v)
+	vw := a.readView(queryParameters, bookShape, v)
 
 	// Check if our filters are valid
 	data.ValidateFilters(v, &queryParametersData.Filters)
@@ -242,9 +539,7 @@ func (a *applicationDependencies) searchBookHandler(w http.ResponseWriter, r *ht
 	}
 
 	books, metadata, err := a.bookModel.GetAllFilters(
-		queryParametersData.Title,
-		queryParametersData.Genre,
-		queryParametersData.Author,
+		queryParametersData.BookSearch,
 		queryParametersData.Filters,
 	)
 	if err != nil {
@@ -252,13 +547,65 @@ func (a *applicationDependencies) searchBookHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
+	shaped, err := a.viewBooks(vw, books)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	responseData := envelope{
-		"books":     books,
+		"books":     shaped,
 		"@metadata": metadata,
 	}
+
+	if withFacets {
+		facets, err := a.bookModel.Facets(queryParametersData.BookSearch)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		responseData["facets"] = facets
+	}
+
 	err = a.writeJSON(w, http.StatusOK, responseData, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 
 }
+
+// readBookSearch loads the search criteria shared by the search and export
+// endpoints from the query string.
+func (a *applicationDependencies) readBookSearch(queryParameters url.Values, v *validator.Validator) data.BookSearch {
+	search := data.BookSearch{
+		Query:  a.getSingleQueryParameter(queryParameters, "q", ""),
+		Title:  a.getSingleQueryParameter(queryParameters, "title", ""),
+		Genre:  a.getSingleQueryParameter(queryParameters, "genre", ""),
+		Author: a.getSingleQueryParameter(queryParameters, "author", ""),
+		ISBN:   a.getSingleQueryParameter(queryParameters, "isbn", ""),
+
+		GenreSlug: a.getSingleQueryParameter(queryParameters, "genre_slug", ""),
+		AuthorID:  int64(a.getSingleIntegerParameter(queryParameters, "author_id", 0, v)),
+		Decade:    a.getSingleIntegerParameter(queryParameters, "decade", 0, v),
+		SeriesID:  int64(a.getSingleIntegerParameter(queryParameters, "series_id", 0, v)),
+		Tag:       data.NormalizeTag(a.getSingleQueryParameter(queryParameters, "tag", "")),
+
+		Language:    data.NormalizeLanguage(a.getSingleQueryParameter(queryParameters, "language", "")),
+		PublisherID: int64(a.getSingleIntegerParameter(queryParameters, "publisher_id", 0, v)),
+		Format:      a.getSingleQueryParameter(queryParameters, "book_format", ""),
+	}
+	search.PagesGTE = a.getOptionalIntegerParameter(queryParameters, "pages_gte", v)
+	search.PagesLTE = a.getOptionalIntegerParameter(queryParameters, "pages_lte", v)
+	search.OriginalYearGTE = a.getOptionalIntegerParameter(queryParameters, "original_year_gte", v)
+	search.OriginalYearLTE = a.getOptionalIntegerParameter(queryParameters, "original_year_lte", v)
+
+	// either ISBN form is accepted, lookups use the canonical ISBN-13
+	if search.ISBN != "" {
+		search.ISBN = data.ValidateISBN(v, search.ISBN)
+	}
+
+	search.Rating = a.getOptionalIntegerParameter(queryParameters, "rating", v)
+	data.ValidateBookSearch(v, search)
+
+	return search
+}
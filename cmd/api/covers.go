@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/RayMC17/bookclub-api/internal/covers"
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/julienschmidt/httprouter"
+)
+
+// uploadCoverHandler replaces a book's cover with the image in the request
+// body. The Content-Type header must name the image format.
+func (a *applicationDependencies) uploadCoverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		contentType = ""
+	}
+
+	book, err := a.bookModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, covers.MaxBytes)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			message := fmt.Sprintf("cover must not be larger than %d bytes", maxBytesError.Limit)
+			a.errorResponseJSON(w, r, http.StatusRequestEntityTooLarge, message)
+		default:
+			a.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	files, err := covers.Process(body, contentType)
+	if err != nil {
+		switch {
+		case errors.Is(err, covers.ErrUnsupportedType):
+			a.errorResponseJSON(w, r, http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, covers.ErrTypeMismatch),
+			errors.Is(err, covers.ErrInvalidImage),
+			errors.Is(err, covers.ErrTooManyPixels):
+			a.failedValidationResponse(w, r, map[string]string{"cover": err.Error()})
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, file := range files {
+		err = a.coverStorage.Put(file.Name, file.Data)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	key := files[0].Name
+	previous, err := a.bookModel.SetCover(book.ID, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if previous != "" && previous != key {
+		a.removeCover(previous)
+	}
+
+	book.CoverKey = key
+	book.Cover = covers.URLsFor(key)
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// removeCover deletes the files of a replaced cover unless another book
+// still uses the same image. Failures are only logged since the new cover
+// is already in place.
+func (a *applicationDependencies) removeCover(key string) {
+	inUse, err := a.bookModel.CoverInUse(key)
+	if err != nil {
+		a.logger.Error(err.Error())
+		return
+	}
+	if inUse {
+		return
+	}
+	for _, name := range covers.Names(key) {
+		err = a.coverStorage.Delete(name)
+		if err != nil {
+			a.logger.Error(err.Error(), "cover", name)
+		}
+	}
+}
+
+// serveCoverHandler returns a stored cover file. The names are derived from
+// the content, so the files can be cached for as long as clients like.
+func (a *applicationDependencies) serveCoverHandler(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	file, modTime, err := a.coverStorage.Open(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, covers.ErrFileNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", covers.ContentType(name))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+name+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, modTime, file)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// listDuplicatesHandler lists pairs of books that are probably the same
+// book entered twice, for a catalog admin to merge.
+func (a *applicationDependencies) listDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 20, v)
+	filters.Sort = "-score"
+	filters.SortSafelist = []string{"-score"}
+
+	minScore := 0.7
+	if value := queryParameters.Get("min_score"); value != "" {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			v.AddError("min_score", "must be a number")
+		}
+		minScore = score
+	}
+
+	data.ValidateFilters(v, &filters)
+	v.Check(minScore >= 0 && minScore <= 1, "min_score", "must be between 0 and 1")
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	pairs, metadata, err := a.bookModel.Duplicates(minScore, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"duplicates": pairs,
+		"@metadata":  metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// mergeBookHandler merges the duplicate named in the body into the book in
+// the URL. The duplicate's id keeps resolving to the survivor afterwards.
+func (a *applicationDependencies) mergeBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		DuplicateID int `json:"duplicate_id"`
+	}
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.DuplicateID > 0, "duplicate_id", "must be provided")
+	v.Check(input.DuplicateID != id, "duplicate_id", "must not be the book itself")
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	survivor, err := a.bookModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !ifMatch(r, survivor.Version) {
+		a.versionMismatchResponse(w, r, http.StatusPreconditionFailed, "book", survivor, survivor.Version)
+		return
+	}
+
+	coverKey, err := a.bookModel.Merge(id, input.DuplicateID, a.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if coverKey != "" {
+		a.removeCover(coverKey)
+	}
+
+	book, err := a.bookModel.Get(id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(book.Version))
+	err = a.writeJSON(w, http.StatusOK, envelope{"book": book}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// bookNotFoundResponse sends a missing book's client on to the book it was
+// merged into, or a 404 when it never was.
+func (a *applicationDependencies) bookNotFoundResponse(w http.ResponseWriter, r *http.Request, id int) {
+	bookID, err := a.bookModel.Redirect(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	location := fmt.Sprintf("/api/v1/books/%d", bookID)
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	headers := make(http.Header)
+	headers.Set("Location", location)
+	response := envelope{
+		"message": "the book has been merged into another one",
+		"book_id": bookID,
+	}
+	err = a.writeJSON(w, http.StatusMovedPermanently, response, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
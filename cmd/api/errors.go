@@ -86,3 +86,32 @@ func (a *applicationDependencies) inactiveAccountResponse(w http.ResponseWriter,
 	message := "your user account must be activated to access this resource"
 	a.errorResponseJSON(w, r, http.StatusForbidden, message)
 }
+
+// notPermittedResponse sends a 403 when the user lacks the permission a resource needs.
+func (a *applicationDependencies) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
+
+// metadataUnavailableResponse sends a 503 when no book metadata provider is
+// configured or its index is still being built.
+func (a *applicationDependencies) metadataUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "book metadata lookups are not available on this server at the moment"
+	a.errorResponseJSON(w, r, http.StatusServiceUnavailable, message)
+}
+
+// versionMismatchResponse is sent when a conditional update loses against a
+// newer version, with status 412 for a failed If-Match and 409 for a
+// concurrent edit. The current representation is included under key so the
+// client can merge its changes and retry.
+func (a *applicationDependencies) versionMismatchResponse(w http.ResponseWriter, r *http.Request, status int, key string, current any, version int) {
+	message := "the record has been modified since you last retrieved it"
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+	err := a.writeJSON(w, status, envelope{"error": message, key: current}, headers)
+	if err != nil {
+		a.logError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// bookEncoder writes books to a stream in one export format.
+type bookEncoder interface {
+	Begin() error
+	Encode(book *data.Book) error
+	End() error
+}
+
+func (a *applicationDependencies) exportBooksHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+	v := validator.New()
+
+	search := a.readBookSearch(queryParameters, v)
+	format := a.getSingleQueryParameter(queryParameters, "format", "csv")
+
+	v.Check(validator.In(format, "csv", "ndjson", "marcxml"), "format", "must be one of 'csv', 'ndjson' or 'marcxml'")
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var enc bookEncoder
+	var contentType, extension string
+	switch format {
+	case "csv":
+		enc, contentType, extension = &csvBookEncoder{w: csv.NewWriter(w)}, "text/csv", "csv"
+	case "ndjson":
+		enc, contentType, extension = &ndjsonBookEncoder{enc: json.NewEncoder(w)}, "application/x-ndjson", "ndjson"
+	case "marcxml":
+		enc, contentType, extension = &marcXMLBookEncoder{w: w, enc: xml.NewEncoder(w)}, "application/marcxml+xml", "xml"
+	}
+
+	// the server's write timeout is sized for regular requests, not for
+	// streaming the whole catalog
+	err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(5 * time.Minute))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the headers are only sent once the query has produced its first row so
+	// that a failing query can still be reported as a 500
+	started := false
+	begin := func() error {
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="books.%s"`, extension))
+		w.WriteHeader(http.StatusOK)
+		return enc.Begin()
+	}
+
+	err = a.bookModel.Export(r.Context(), search, func(book *data.Book) error {
+		if !started {
+			err := begin()
+			if err != nil {
+				return err
+			}
+		}
+		return enc.Encode(book)
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = enc.End()
+	}
+	if err != nil {
+		if !started {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		// part of the file has already been sent so all we can do is log
+		a.logError(r, err)
+	}
+}
+
+// csvBookEncoder writes the same columns the import endpoint reads.
+type csvBookEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvBookEncoder) Begin() error {
+	return e.w.Write([]string{"id", "title", "authors", "isbn", "publication_date", "genre", "description",
+		"publisher", "language", "pages", "format", "duration_minutes", "original_year", "average_rating"})
+}
+
+func (e *csvBookEncoder) Encode(book *data.Book) error {
+	publicationDate := ""
+	if !book.PublicationDate.IsZero() {
+		publicationDate = book.PublicationDate.Format("2006-01-02")
+	}
+
+	return e.w.Write([]string{
+		strconv.Itoa(book.ID),
+		book.Title,
+		strings.Join(book.Authors, "; "),
+		book.ISBN,
+		publicationDate,
+		book.Genre,
+		book.Description,
+		book.Publisher,
+		book.Language,
+		formatOptionalInt(book.Pages),
+		book.Format,
+		formatOptionalInt(book.DurationMinutes),
+		formatOptionalInt(book.OriginalYear),
+		strconv.FormatFloat(book.AverageRating, 'f', 2, 64),
+	})
+}
+
+// formatOptionalInt leaves the column empty when the value is unknown.
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func (e *csvBookEncoder) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonBookEncoder writes one JSON object per line.
+type ndjsonBookEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonBookEncoder) Begin() error { return nil }
+
+func (e *ndjsonBookEncoder) Encode(book *data.Book) error {
+	return e.enc.Encode(book)
+}
+
+func (e *ndjsonBookEncoder) End() error { return nil }
+
+// MARCXML (MARC 21 slim) representation of a book.
+type marcRecord struct {
+	XMLName       xml.Name           `xml:"record"`
+	Leader        string             `xml:"leader"`
+	ControlFields []marcControlField `xml:"controlfield"`
+	DataFields    []marcDataField    `xml:"datafield"`
+}
+
+type marcControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcDataField struct {
+	Tag       string         `xml:"tag,attr"`
+	Ind1      string         `xml:"ind1,attr"`
+	Ind2      string         `xml:"ind2,attr"`
+	Subfields []marcSubfield `xml:"subfield"`
+}
+
+type marcSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+func newMARCRecord(book *data.Book) marcRecord {
+	field := func(tag, ind1, ind2, code, value string) marcDataField {
+		return marcDataField{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: []marcSubfield{{Code: code, Value: value}}}
+	}
+
+	record := marcRecord{
+		// language material, monograph
+		Leader:        "00000nam a2200000 i 4500",
+		ControlFields: []marcControlField{{Tag: "001", Value: strconv.Itoa(book.ID)}},
+	}
+
+	record.DataFields = append(record.DataFields, field("020", " ", " ", "a", book.ISBN))
+	// the first author is the main entry, the rest are added entries at the end
+	if len(book.Authors) > 0 {
+		record.DataFields = append(record.DataFields, field("100", "1", " ", "a", book.Authors[0]))
+	}
+	record.DataFields = append(record.DataFields, field("245", "1", "0", "a", book.Title))
+	if !book.PublicationDate.IsZero() {
+		record.DataFields = append(record.DataFields, field("264", " ", "1", "c", strconv.Itoa(book.PublicationDate.Year())))
+	}
+	if book.Description != "" {
+		record.DataFields = append(record.DataFields, field("520", " ", " ", "a", book.Description))
+	}
+	if book.Genre != "" {
+		record.DataFields = append(record.DataFields, field("655", " ", "4", "a", book.Genre))
+	}
+	for _, author := range book.Authors[min(1, len(book.Authors)):] {
+		record.DataFields = append(record.DataFields, field("700", "1", " ", "a", author))
+	}
+
+	return record
+}
+
+type marcXMLBookEncoder struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+var marcCollection = xml.StartElement{
+	Name: xml.Name{Local: "collection"},
+	Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: "http://www.loc.gov/MARC21/slim"}},
+}
+
+func (e *marcXMLBookEncoder) Begin() error {
+	_, err := io.WriteString(e.w, xml.Header)
+	if err != nil {
+		return err
+	}
+	return e.enc.EncodeToken(marcCollection)
+}
+
+func (e *marcXMLBookEncoder) Encode(book *data.Book) error {
+	return e.enc.Encode(newMARCRecord(book))
+}
+
+func (e *marcXMLBookEncoder) End() error {
+	err := e.enc.EncodeToken(marcCollection.End())
+	if err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
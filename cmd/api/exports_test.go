@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+)
+
+// emptyDriver answers every query with no rows, which is enough to run the
+// export handler without a database.
+type emptyDriver struct{}
+
+func (emptyDriver) Open(string) (driver.Conn, error) { return emptyConn{}, nil }
+
+type emptyConn struct{}
+
+func (emptyConn) Prepare(string) (driver.Stmt, error) { return emptyStmt{}, nil }
+func (emptyConn) Close() error                        { return nil }
+func (emptyConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+type emptyStmt struct{}
+
+func (emptyStmt) Close() error                               { return nil }
+func (emptyStmt) NumInput() int                              { return -1 }
+func (emptyStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (emptyStmt) Query([]driver.Value) (driver.Rows, error)  { return emptyRows{}, nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("empty", emptyDriver{})
+}
+
+func TestExportBooksFormat(t *testing.T) {
+	db, err := sql.Open("empty", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	app := &applicationDependencies{
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		bookModel: data.BookModel{DB: db},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(app.exportBooksHandler))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		query       string
+		status      int
+		contentType string
+	}{
+		{"default", "", http.StatusOK, "text/csv"},
+		{"ndjson", "?format=ndjson", http.StatusOK, "application/x-ndjson"},
+		{"marcxml", "?format=marcxml", http.StatusOK, "application/marcxml+xml"},
+		{"ndjson of ebooks", "?format=ndjson&book_format=ebook", http.StatusOK, "application/x-ndjson"},
+		{"unknown export format", "?format=pdf", http.StatusUnprocessableEntity, "application/json"},
+		{"unknown book format", "?format=ndjson&book_format=vinyl", http.StatusUnprocessableEntity, "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Get(ts.URL + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.status {
+				body, _ := io.ReadAll(res.Body)
+				t.Fatalf("status = %d, want %d: %s", res.StatusCode, tt.status, body)
+			}
+			if got := res.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// Sparse fieldsets and includes on read endpoints. fields= lists the JSON
+// fields to return, where a dotted name such as books.title picks a field of
+// an included resource. include= embeds related resources, which are loaded
+// with one query per include whatever the number of rows. A response without
+// either parameter is written exactly as before.
+
+// shape lists the fields a read endpoint can return. The empty path is the
+// resource itself and every other path is a resource it can include.
+type shape map[string][]string
+
+var (
+	bookShape = shape{
+		"":        jsonFields(data.Book{}),
+		"reviews": jsonFields(data.Review{}),
+	}
+	readingListShape = shape{
+		"":              jsonFields(data.ReadingList{}),
+		"books":         jsonFields(data.ListedBook{}),
+		"books.reviews": jsonFields(data.Review{}),
+	}
+	reviewShape = shape{
+		"":     jsonFields(data.Review{}),
+		"book": jsonFields(data.Book{}),
+	}
+	userShape = shape{
+		"":        jsonFields(data.User{}),
+		"lists":   jsonFields(data.ReadingList{}),
+		"reviews": jsonFields(data.Review{}),
+	}
+)
+
+// jsonFields returns the JSON names of a struct's fields, including those of
+// embedded structs.
+func jsonFields(v any) []string {
+	t := reflect.TypeOf(v)
+	fields := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			fields = append(fields, jsonFields(reflect.Zero(embedded).Interface())...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// view is what a client asked for with fields= and include=.
+type view struct {
+	// fields by path, a path without an entry returns every field
+	fields   map[string]map[string]bool
+	includes map[string]bool
+}
+
+// readView reads the fields and include query parameters, checking them
+// against the shape of the endpoint.
+func (a *applicationDependencies) readView(queryParameters url.Values, s shape, v *validator.Validator) view {
+	vw := view{fields: map[string]map[string]bool{}, includes: map[string]bool{}}
+
+	for _, include := range splitList(a.getSingleQueryParameter(queryParameters, "include", "")) {
+		if _, ok := s[include]; !ok || include == "" {
+			v.AddError("include", fmt.Sprintf("cannot include %q", include))
+			continue
+		}
+		// including books.reviews implies the books
+		for path := include; path != ""; path, _, _ = cutLast(path) {
+			vw.includes[path] = true
+		}
+	}
+
+	for _, field := range splitList(a.getSingleQueryParameter(queryParameters, "fields", "")) {
+		path, name, _ := cutLast(field)
+		allowed, ok := s[path]
+		if !ok || !validator.In(name, allowed...) {
+			v.AddError("fields", fmt.Sprintf("unknown field %q", field))
+			continue
+		}
+		if path != "" && !vw.includes[path] {
+			v.AddError("fields", fmt.Sprintf("%q needs include=%s", field, path))
+			continue
+		}
+		if vw.fields[path] == nil {
+			vw.fields[path] = map[string]bool{}
+		}
+		vw.fields[path][name] = true
+	}
+
+	return vw
+}
+
+// plain reports whether the client asked for the default representation.
+func (vw view) plain() bool {
+	return len(vw.fields) == 0 && len(vw.includes) == 0
+}
+
+// object renders v as a JSON object with the requested fields of path and
+// the embedded includes. The id is always kept so clients can tell the
+// objects apart.
+func (vw view) object(path string, v any, embed map[string]any) (map[string]any, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(js, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := vw.fields[path]
+	obj := make(map[string]any, len(fields)+len(embed))
+	for name, value := range fields {
+		if wanted == nil || wanted[name] || name == "id" {
+			obj[name] = value
+		}
+	}
+	for name, value := range embed {
+		obj[name] = value
+	}
+	return obj, nil
+}
+
+// objects renders every value at path without any includes.
+func objects[T any](vw view, path string, values []T) ([]map[string]any, error) {
+	objs := make([]map[string]any, len(values))
+	for i, value := range values {
+		obj, err := vw.object(path, value, nil)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+	return objs, nil
+}
+
+// shapeBooks renders books found at path. books holds *data.Book or
+// *data.ListedBook values and ids their book ids. The reviews of all of them
+// are loaded at once when path.reviews is included.
+func (a *applicationDependencies) shapeBooks(vw view, path string, books []any, ids []int64) ([]map[string]any, error) {
+	reviewsPath := joinPath(path, "reviews")
+	var reviews map[int64][]*data.Review
+	if vw.includes[reviewsPath] {
+		var err error
+		reviews, err = a.reviewModel.GetAllForBooks(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	objs := make([]map[string]any, len(books))
+	for i, book := range books {
+		var embed map[string]any
+		if reviews != nil {
+			shaped, err := objects(vw, reviewsPath, reviews[ids[i]])
+			if err != nil {
+				return nil, err
+			}
+			embed = map[string]any{"reviews": shaped}
+		}
+		obj, err := vw.object(path, book, embed)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+	return objs, nil
+}
+
+// viewBooks applies the view to a page of books.
+func (a *applicationDependencies) viewBooks(vw view, books []*data.Book) (any, error) {
+	if vw.plain() {
+		return books, nil
+	}
+	values := make([]any, len(books))
+	ids := make([]int64, len(books))
+	for i, book := range books {
+		values[i], ids[i] = book, int64(book.ID)
+	}
+	return a.shapeBooks(vw, "", values, ids)
+}
+
+// viewBook applies the view to a single book.
+func (a *applicationDependencies) viewBook(vw view, book *data.Book) (any, error) {
+	if vw.plain() {
+		return book, nil
+	}
+	objs, err := a.shapeBooks(vw, "", []any{book}, []int64{int64(book.ID)})
+	if err != nil {
+		return nil, err
+	}
+	return objs[0], nil
+}
+
+// viewReadingLists applies the view to reading lists.
+func (a *applicationDependencies) viewReadingLists(vw view, lists []*data.ReadingList) (any, error) {
+	if vw.plain() {
+		return lists, nil
+	}
+	return a.shapeReadingLists(vw, lists)
+}
+
+// viewReadingList applies the view to a single reading list.
+func (a *applicationDependencies) viewReadingList(vw view, list *data.ReadingList) (any, error) {
+	if vw.plain() {
+		return list, nil
+	}
+	objs, err := a.shapeReadingLists(vw, []*data.ReadingList{list})
+	if err != nil {
+		return nil, err
+	}
+	return objs[0], nil
+}
+
+// shapeReadingLists renders reading lists, loading the books of all of them
+// in one query when they are included.
+func (a *applicationDependencies) shapeReadingLists(vw view, lists []*data.ReadingList) ([]map[string]any, error) {
+	var books map[int][]*data.ListedBook
+	var shapedBooks []map[string]any
+	if vw.includes["books"] {
+		listIDs := make([]int, len(lists))
+		for i, list := range lists {
+			listIDs[i] = list.ID
+		}
+		var err error
+		books, err = a.readingListModel.GetBooks(listIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		// shape the books of every list together so their reviews are
+		// loaded at once as well
+		values, ids := []any{}, []int64{}
+		for _, list := range lists {
+			for _, book := range books[list.ID] {
+				values = append(values, book)
+				ids = append(ids, int64(book.ID))
+			}
+		}
+		shapedBooks, err = a.shapeBooks(vw, "books", values, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	objs := make([]map[string]any, len(lists))
+	for i, list := range lists {
+		var embed map[string]any
+		if books != nil {
+			n := len(books[list.ID])
+			embed = map[string]any{"books": shapedBooks[:n]}
+			shapedBooks = shapedBooks[n:]
+		}
+		obj, err := vw.object("", list, embed)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+	return objs, nil
+}
+
+// viewReviews applies the view to reviews, loading their books in one query
+// when they are included.
+func (a *applicationDependencies) viewReviews(vw view, reviews []*data.Review) (any, error) {
+	if vw.plain() {
+		return reviews, nil
+	}
+
+	var books map[int64]*data.Book
+	if vw.includes["book"] {
+		ids := make([]int64, len(reviews))
+		for i, review := range reviews {
+			ids[i] = review.BookID
+		}
+		var err error
+		books, err = a.bookModel.GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	objs := make([]map[string]any, len(reviews))
+	for i, review := range reviews {
+		var embed map[string]any
+		if books != nil {
+			var book any
+			if b, ok := books[review.BookID]; ok {
+				obj, err := vw.object("book", b, nil)
+				if err != nil {
+					return nil, err
+				}
+				book = obj
+			}
+			embed = map[string]any{"book": book}
+		}
+		obj, err := vw.object("", review, embed)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+	return objs, nil
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// cutLast splits a dotted path into its parent and last segment.
+func cutLast(path string) (parent, name string, found bool) {
+	i := strings.LastIndexByte(path, '.')
+	if i < 0 {
+		return "", path, false
+	}
+	return path[:i], path[i+1:], true
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// viewUser applies the view to a user profile. Its lists and reviews are
+// loaded with one query each when they are included.
+func (a *applicationDependencies) viewUser(vw view, user *data.User) (any, error) {
+	if vw.plain() {
+		return user, nil
+	}
+
+	embed := map[string]any{}
+	if vw.includes["lists"] {
+		lists, err := a.readingListModel.GetAllByUser(int64(user.ID))
+		if err != nil {
+			return nil, err
+		}
+		embed["lists"], err = objects(vw, "lists", lists)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if vw.includes["reviews"] {
+		reviews, err := a.reviewModel.GetAllByUser(int64(user.ID))
+		if err != nil {
+			return nil, err
+		}
+		embed["reviews"], err = objects(vw, "reviews", reviews)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vw.object("", user, embed)
+}
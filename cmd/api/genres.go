@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// listGenresHandler returns the whole genre taxonomy as a tree.
+func (a *applicationDependencies) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := a.genreModel.GetAll()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"genres": data.BuildGenreTree(genres)}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) getGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	genre, err := a.genreModel.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string `json:"name"`
+		Slug     string `json:"slug"`
+		ParentID *int64 `json:"parent_id"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	genre := &data.Genre{
+		Name:     input.Name,
+		Slug:     input.Slug,
+		ParentID: input.ParentID,
+	}
+	if genre.Slug == "" {
+		genre.Slug = data.Slugify(genre.Name)
+	}
+
+	v := validator.New()
+	data.ValidateGenre(v, genre)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.genreModel.Insert(genre)
+	if err != nil {
+		a.genreWriteError(w, r, v, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/genres/%d", genre.ID))
+	err = a.writeJSON(w, http.StatusCreated, envelope{"genre": genre}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) updateGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	genre, err := a.genreModel.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// a null parent_id moves the genre to the top level, so it is kept raw
+	// to tell it apart from a missing field
+	var input struct {
+		Name     *string         `json:"name"`
+		Slug     *string         `json:"slug"`
+		ParentID json.RawMessage `json:"parent_id"`
+	}
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		genre.Name = *input.Name
+	}
+	if input.Slug != nil {
+		genre.Slug = *input.Slug
+	}
+	if input.ParentID != nil {
+		genre.ParentID = nil
+		err = json.Unmarshal(input.ParentID, &genre.ParentID)
+		if err != nil {
+			a.badRequestResponse(w, r, errors.New("body contains incorrect JSON type for field \"parent_id\""))
+			return
+		}
+	}
+	genre.Children = nil
+
+	v := validator.New()
+	data.ValidateGenre(v, genre)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.genreModel.Update(genre)
+	if err != nil {
+		a.genreWriteError(w, r, v, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) deleteGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.genreModel.Delete(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrGenreHasChildren):
+			a.errorResponseJSON(w, r, http.StatusConflict, "the genre still has child genres, move or delete them first")
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "genre successfully deleted"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// genreWriteError sends the response for an error from inserting or updating
+// a genre.
+func (a *applicationDependencies) genreWriteError(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, data.ErrDuplicateSlug):
+		v.AddError("slug", "a genre with this slug already exists")
+		a.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrParentGenre):
+		v.AddError("parent_id", "must be an existing genre")
+		a.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrGenreCycle):
+		v.AddError("parent_id", "must not be the genre itself or one of its descendants")
+		a.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrEditConfilct):
+		a.editConflictResponse(w, r)
+	default:
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,7 +11,7 @@ import (
 	"strconv"
 	"strings"
 
-	// "github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/data"
 	"github.com/RayMC17/bookclub-api/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -100,6 +101,40 @@ func (a *applicationDependencies) getSingleIntegerParameter(queryParameters url.
 	return intValue
 }
 
+// getOptionalIntegerParameter returns an integer query parameter value, or
+// nil if it is not present.
+func (a *applicationDependencies) getOptionalIntegerParameter(queryParameters url.Values, key string, v *validator.Validator) *int {
+	if queryParameters.Get(key) == "" {
+		return nil
+	}
+	value := a.getSingleIntegerParameter(queryParameters, key, 0, v)
+	return &value
+}
+
+// getSingleBooleanParameter returns a boolean query parameter value or a default value if not present.
+func (a *applicationDependencies) getSingleBooleanParameter(queryParameters url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	result := queryParameters.Get(key)
+	if result == "" {
+		return defaultValue
+	}
+
+	boolValue, err := strconv.ParseBool(result)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return boolValue
+}
+
+// readCursor reads the cursor and total query parameters into the filters.
+// The total is counted by default only for offset paging, so clients that
+// follow cursors through a large collection do not pay for it on every page.
+func (a *applicationDependencies) readCursor(queryParameters url.Values, filters *data.Filters, v *validator.Validator) {
+	filters.Cursor = a.getSingleQueryParameter(queryParameters, "cursor", "")
+	filters.WithTotal = a.getSingleBooleanParameter(queryParameters, "total", filters.Cursor == "", v)
+}
+
 // readIDParam extracts an integer ID parameter from the URL.
 func (a *applicationDependencies) readIDParam(r *http.Request) (int, error) {
 	params := httprouter.ParamsFromContext(r.Context())
@@ -110,6 +145,21 @@ func (a *applicationDependencies) readIDParam(r *http.Request) (int, error) {
 	return id, nil
 }
 
+// namedActions lets a collection-level action such as /api/v1/books/import
+// share a path with /api/v1/books/:id. httprouter does not allow a static
+// segment next to a wildcard, so the action is picked from the :id value and
+// anything else falls through to the regular handler.
+func (a *applicationDependencies) namedActions(actions map[string]http.HandlerFunc, fallback http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if next, ok := actions[params.ByName("id")]; ok {
+			next(w, r)
+			return
+		}
+		fallback(w, r)
+	}
+}
+
 func (a *applicationDependencies) background(fn func()) {
 	a.wg.Add(1)
 	go func() {
@@ -123,3 +173,58 @@ func (a *applicationDependencies) background(fn func()) {
 		fn() //running the function that was passed to run as parameter
 	}()
 }
+
+// etag turns a record version into a strong entity tag.
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// representationETag tags what a GET actually sends back. It starts with
+// the version, so it still satisfies If-Match for that version, and
+// changes with everything else in the body: aggregates that are refreshed
+// without a new version, related records and the fields and includes asked
+// for.
+func representationETag(version int, body any) (string, error) {
+	js, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(js)
+	return fmt.Sprintf(`"%d-%x"`, version, sum[:8]), nil
+}
+
+// etagListMatches reports whether an If-Match header value names the given
+// version, either by its plain tag or a representation tag of it. An empty
+// header never matches.
+func etagListMatches(header string, version int) bool {
+	current := etag(version)
+	prefix := fmt.Sprintf(`"%d-`, version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current || strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch reports whether a conditional update may go ahead. Requests
+// without an If-Match header are always allowed.
+func ifMatch(r *http.Request, version int) bool {
+	header := r.Header.Get("If-Match")
+	return header == "" || etagListMatches(header, version)
+}
+
+// notModified reports whether the client already has the representation
+// tagged current, in which case a 304 has been sent.
+func notModified(w http.ResponseWriter, r *http.Request, current string) bool {
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			w.Header().Set("ETag", current)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+const (
+	importMaxBytes = 10 << 20 // 10 MB
+	// files with more rows than this are processed in the background
+	importAsyncThreshold = 500
+)
+
+// importInput is one row of an uploaded file. The JSON tags are used by the
+// NDJSON reader and the CSV header uses the same names.
+type importInput struct {
+	Title           string   `json:"title"`
+	Authors         []string `json:"authors"`
+	ISBN            string   `json:"isbn"`
+	PublicationDate string   `json:"publication_date"`
+	Genre           string   `json:"genre"`
+	Description     string   `json:"description"`
+	Publisher       string   `json:"publisher"`
+	Language        string   `json:"language"`
+	Pages           *int     `json:"pages"`
+	Format          string   `json:"format"`
+	DurationMinutes *int     `json:"duration_minutes"`
+	OriginalYear    *int     `json:"original_year"`
+}
+
+// importRecord is a parsed row along with any errors found while parsing it.
+type importRecord struct {
+	line   int
+	book   *data.Book
+	errors map[string]string
+}
+
+func (in importInput) record(line int) importRecord {
+	rec := importRecord{
+		line:   line,
+		errors: make(map[string]string),
+		book: &data.Book{
+			Title:           in.Title,
+			Authors:         in.Authors,
+			ISBN:            in.ISBN,
+			Genre:           in.Genre,
+			Description:     in.Description,
+			Publisher:       in.Publisher,
+			Language:        data.NormalizeLanguage(in.Language),
+			Pages:           in.Pages,
+			Format:          in.Format,
+			DurationMinutes: in.DurationMinutes,
+			OriginalYear:    in.OriginalYear,
+		},
+	}
+
+	if in.PublicationDate != "" {
+		date, err := time.Parse("2006-01-02", in.PublicationDate)
+		if err != nil {
+			rec.errors["publication_date"] = "must be a date in the format YYYY-MM-DD"
+		} else {
+			rec.book.PublicationDate = date
+		}
+	}
+
+	return rec
+}
+
+func (a *applicationDependencies) importBooksHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+	v := validator.New()
+
+	format := a.getSingleQueryParameter(queryParameters, "format", importFormat(r.Header.Get("Content-Type")))
+	onDuplicate := a.getSingleQueryParameter(queryParameters, "on_duplicate", "skip")
+	dryRun := a.getSingleBooleanParameter(queryParameters, "dry_run", false, v)
+
+	data.ValidateImportOptions(v, format, onDuplicate)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+
+	var records []importRecord
+	var err error
+	switch format {
+	case "csv":
+		records, err = readCSVImport(r.Body)
+	default:
+		records, err = readNDJSONImport(r.Body)
+	}
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			a.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			a.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	if len(records) == 0 {
+		a.badRequestResponse(w, r, errors.New("file must contain at least one row"))
+		return
+	}
+
+	job := &data.BookImport{
+		UserID:      a.contextGetUser(r).ID,
+		Status:      data.ImportRunning,
+		DryRun:      dryRun,
+		OnDuplicate: onDuplicate,
+		TotalRows:   len(records),
+	}
+
+	err = a.importModel.Insert(job)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// small files are imported straight away so the client gets the report
+	// in the response
+	if len(records) <= importAsyncThreshold {
+		err = a.finishImport(job, records)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = a.writeJSON(w, http.StatusOK, envelope{"import": job}, nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/imports/%d", job.ID))
+	err = a.writeJSON(w, http.StatusAccepted, envelope{"import": job}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+
+	a.background(func() {
+		err := a.finishImport(job, records)
+		if err != nil {
+			a.logger.Error(err.Error(), "import_id", job.ID)
+		}
+	})
+}
+
+func (a *applicationDependencies) getImportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	job, err := a.importModel.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// only the member who uploaded the file can see its report
+	if job.UserID != a.contextGetUser(r).ID {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"import": job}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// finishImport runs the import and stores the outcome on the job.
+func (a *applicationDependencies) finishImport(job *data.BookImport, records []importRecord) error {
+	job.Report = a.runImport(job, records)
+
+	err := a.importModel.Complete(job)
+	if err != nil {
+		// the report says which rows were written, so a failed job keeps it
+		failErr := a.importModel.Fail(job, err)
+		if failErr != nil {
+			return failErr
+		}
+		return err
+	}
+	return nil
+}
+
+// runImport validates every row and, unless this is a dry run, writes the
+// valid ones. Rows whose ISBN already exists are skipped or updated depending
+// on job.OnDuplicate. A row that cannot be written is reported as failed and
+// the import carries on, so the report always says which rows made it in.
+func (a *applicationDependencies) runImport(job *data.BookImport, records []importRecord) *data.ImportReport {
+	report := &data.ImportReport{Rows: []data.ImportRow{}}
+	// ISBNs seen earlier in the same file, so repeated rows are treated as
+	// duplicates even during a dry run
+	seen := make(map[string]int)
+
+	for _, rec := range records {
+		row := data.ImportRow{Line: rec.line, ISBN: rec.book.ISBN}
+
+		v := validator.New()
+		for key, message := range rec.errors {
+			v.AddError(key, message)
+		}
+		data.ValidateBook(v, rec.book)
+		if !v.Valid() {
+			row.Status = data.ImportRowInvalid
+			row.Errors = v.Errors
+			report.Add(row)
+			continue
+		}
+		// validation rewrites the ISBN in its canonical form
+		row.ISBN = rec.book.ISBN
+
+		id, duplicate := seen[rec.book.ISBN]
+		if !duplicate {
+			existing, err := a.bookModel.GetByISBN(rec.book.ISBN)
+			switch {
+			case err == nil:
+				id, duplicate = existing.ID, true
+			case !errors.Is(err, data.ErrRecordNotFound):
+				report.Add(a.failedImportRow(job, row, err))
+				continue
+			}
+		}
+
+		var err error
+		switch {
+		case duplicate && job.OnDuplicate == "skip":
+			row.Status = data.ImportRowSkipped
+			row.BookID = id
+		case duplicate:
+			row.Status = data.ImportRowUpdated
+			row.BookID = id
+			// a book only created earlier in a dry run has nothing stored
+			// to update
+			if id == 0 {
+				break
+			}
+			var current *data.Book
+			current, err = a.bookModel.Get(id)
+			if err != nil {
+				break
+			}
+			applyImportRow(current, rec.book)
+			v := validator.New()
+			data.ValidateBook(v, current)
+			if !v.Valid() {
+				row.Status = data.ImportRowInvalid
+				row.BookID = 0
+				row.Errors = v.Errors
+				report.Add(row)
+				continue
+			}
+			if !job.DryRun {
+				err = a.bookModel.Update(current, job.UserID)
+			}
+		default:
+			row.Status = data.ImportRowCreated
+			if !job.DryRun {
+				err = a.bookModel.Insert(rec.book, job.UserID)
+				row.BookID = rec.book.ID
+			}
+		}
+		if err != nil {
+			report.Add(a.failedImportRow(job, row, err))
+			continue
+		}
+
+		seen[rec.book.ISBN] = row.BookID
+		report.Add(row)
+	}
+
+	return report
+}
+
+// applyImportRow copies the values given in an imported row onto the stored
+// book. Columns the row leaves empty keep their stored values, and the other
+// contributors and curated genres stay unless the row changes the genre.
+func applyImportRow(book, row *data.Book) {
+	if row.Title != "" {
+		book.Title = row.Title
+	}
+	if len(row.Authors) > 0 {
+		book.Authors = row.Authors
+	}
+	if !row.PublicationDate.IsZero() {
+		book.PublicationDate = row.PublicationDate
+	}
+	if row.Genre != "" && row.Genre != book.Genre {
+		book.Genre = row.Genre
+		// matched against the taxonomy again when saved
+		book.Genres = nil
+	}
+	if row.Description != "" {
+		book.Description = row.Description
+	}
+	if row.Publisher != "" {
+		book.Publisher = row.Publisher
+	}
+	if row.Language != "" {
+		book.Language = row.Language
+	}
+	if row.Pages != nil {
+		book.Pages = row.Pages
+	}
+	if row.Format != "" {
+		book.Format = row.Format
+	}
+	if row.DurationMinutes != nil {
+		book.DurationMinutes = row.DurationMinutes
+	}
+	if row.OriginalYear != nil {
+		book.OriginalYear = row.OriginalYear
+	}
+}
+
+// failedImportRow marks a row that could not be written. The error itself
+// is logged rather than shown to the member.
+func (a *applicationDependencies) failedImportRow(job *data.BookImport, row data.ImportRow, err error) data.ImportRow {
+	a.logger.Error(err.Error(), "import_id", job.ID, "line", row.Line)
+
+	message := "could not be saved, please try again"
+	if errors.Is(err, data.ErrEditConfilct) {
+		message = "the book was changed while the import ran, please try again"
+	}
+	row.Status = data.ImportRowFailed
+	row.BookID = 0
+	row.Errors = map[string]string{"book": message}
+	return row
+}
+
+// importFormat works out the upload format from the Content-Type header.
+func importFormat(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return "ndjson"
+	}
+	return ""
+}
+
+// readCSVImport reads a CSV file with a header row. Authors are separated by
+// semicolons within their column.
+func readCSVImport(body io.Reader) ([]importRecord, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"title", "authors", "isbn"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header must include a %q column", required)
+		}
+	}
+
+	records := []importRecord{}
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseError *csv.ParseError
+			if !errors.As(err, &parseError) {
+				return nil, err
+			}
+			records = append(records, importRecord{
+				line:   parseError.Line,
+				book:   &data.Book{},
+				errors: map[string]string{"row": parseError.Err.Error()},
+			})
+			continue
+		}
+
+		get := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return strings.TrimSpace(fields[i])
+		}
+
+		var authors []string
+		for _, author := range strings.Split(get("authors"), ";") {
+			author = strings.TrimSpace(author)
+			if author != "" {
+				authors = append(authors, author)
+			}
+		}
+
+		// whole number columns, left empty when the value is unknown
+		invalid := []string{}
+		getInt := func(name string) *int {
+			value := get(name)
+			if value == "" {
+				return nil
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				invalid = append(invalid, name)
+				return nil
+			}
+			return &n
+		}
+
+		line, _ := reader.FieldPos(0)
+		input := importInput{
+			Title:           get("title"),
+			Authors:         authors,
+			ISBN:            get("isbn"),
+			PublicationDate: get("publication_date"),
+			Genre:           get("genre"),
+			Description:     get("description"),
+			Publisher:       get("publisher"),
+			Language:        get("language"),
+			Pages:           getInt("pages"),
+			Format:          get("format"),
+			DurationMinutes: getInt("duration_minutes"),
+			OriginalYear:    getInt("original_year"),
+		}
+		rec := input.record(line)
+		for _, name := range invalid {
+			rec.errors[name] = "must be a whole number"
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// readNDJSONImport reads one JSON object per line. Blank lines are ignored.
+func readNDJSONImport(body io.Reader) ([]importRecord, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), importMaxBytes)
+
+	records := []importRecord{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var input importInput
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&input)
+		if err != nil {
+			records = append(records, importRecord{
+				line:   line,
+				book:   &data.Book{},
+				errors: map[string]string{"row": err.Error()},
+			})
+			continue
+		}
+
+		records = append(records, input.record(line))
+	}
+
+	return records, scanner.Err()
+}
@@ -10,8 +10,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/RayMC17/bookclub-api/internal/covers"
 	"github.com/RayMC17/bookclub-api/internal/data"
 	"github.com/RayMC17/bookclub-api/internal/mailer"
+	"github.com/RayMC17/bookclub-api/internal/openlibrary"
 	_ "github.com/lib/pq"
 )
 
@@ -39,6 +41,24 @@ type serverConfig struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	openLibrary struct {
+		editions string
+		authors  string
+		indexDir string
+	}
+	covers struct {
+		dir string
+	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	rankings struct {
+		refreshInterval time.Duration
+	}
+	recommendations struct {
+		refreshInterval time.Duration
+	}
 }
 
 type applicationDependencies struct {
@@ -51,6 +71,18 @@ type applicationDependencies struct {
 	readingListModel data.ReadingListModel
 	reviewModel      data.ReviewModel
 	userModel        data.UserModel
+	importModel      data.ImportModel
+	metadata         data.MetadataProvider
+	authorModel      data.AuthorModel
+	permissionModel  data.PermissionModel
+	genreModel       data.GenreModel
+	workModel        data.WorkModel
+	coverStorage     covers.Storage
+	trashModel       data.TrashModel
+	seriesModel      data.SeriesModel
+	tagModel         data.TagModel
+	publisherModel   data.PublisherModel
+	submissionModel  data.SubmissionModel
 }
 
 func main() {
@@ -76,7 +108,14 @@ func main() {
 		settings.cors.trustedOrigins = strings.Fields(val)
 		return nil
 	})
-
+	flag.StringVar(&settings.openLibrary.editions, "openlibrary-editions", "", "Open Library editions dump used to fill in book metadata (optional)")
+	flag.StringVar(&settings.openLibrary.authors, "openlibrary-authors", "", "Open Library authors dump used to resolve author names (optional)")
+	flag.StringVar(&settings.openLibrary.indexDir, "openlibrary-index-dir", "./openlibrary-index", "Directory for the Open Library lookup index")
+	flag.StringVar(&settings.covers.dir, "covers-dir", "./covers", "Directory for uploaded book cover images")
+	flag.DurationVar(&settings.trash.retention, "trash-retention", 30*24*time.Hour, "How long deleted items stay in the trash before they are purged (0 disables purging)")
+	flag.DurationVar(&settings.trash.purgeInterval, "trash-purge-interval", time.Hour, "How often the trash purge runs")
+	flag.DurationVar(&settings.rankings.refreshInterval, "rankings-refresh-interval", 15*time.Minute, "How often the top and trending book rankings are recomputed (0 disables refreshing)")
+	flag.DurationVar(&settings.recommendations.refreshInterval, "recommendations-refresh-interval", time.Hour, "How often the book similarity behind recommendations is rebuilt (0 disables rebuilding)")
 
 	flag.Parse()
 
@@ -92,6 +131,29 @@ func main() {
 	defer db.Close()
 	logger.Info("database connection pool established")
 
+	// Book metadata lookups are only available when a dump is configured.
+	// Building the index can take a long time, so the server starts without
+	// it and lookups report unavailable until it is ready
+	var metadataProvider data.MetadataProvider
+	if settings.openLibrary.editions != "" {
+		logger.Info("loading open library index", "dir", settings.openLibrary.indexDir)
+		provider := openlibrary.LoadInBackground(settings.openLibrary.editions, settings.openLibrary.authors, settings.openLibrary.indexDir, func(err error) {
+			if err != nil {
+				logger.Error("open library index unavailable", "error", err.Error())
+				return
+			}
+			logger.Info("open library index ready")
+		})
+		defer provider.Close()
+		metadataProvider = provider
+	}
+
+	coverStorage, err := covers.NewLocalStorage(settings.covers.dir)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	// Initialize application dependencies
 	appInstance := &applicationDependencies{
 		config:           settings,
@@ -102,8 +164,24 @@ func main() {
 		mailer:           mailer.New(settings.smtp.host, settings.smtp.port, settings.smtp.username, settings.smtp.password, settings.smtp.sender),
 		tokenModel:       data.TokenModel{DB: db},
 		userModel:        data.UserModel{DB: db},
+		importModel:      data.ImportModel{DB: db},
+		metadata:         metadataProvider,
+		authorModel:      data.AuthorModel{DB: db},
+		permissionModel:  data.PermissionModel{DB: db},
+		genreModel:       data.GenreModel{DB: db},
+		workModel:        data.WorkModel{DB: db},
+		coverStorage:     coverStorage,
+		trashModel:       data.TrashModel{DB: db},
+		seriesModel:      data.SeriesModel{DB: db},
+		tagModel:         data.TagModel{DB: db},
+		publisherModel:   data.PublisherModel{DB: db},
+		submissionModel:  data.SubmissionModel{DB: db},
 	}
 
+	appInstance.startTrashPurge()
+	appInstance.startRankingRefresh()
+	appInstance.startSimilarityRefresh()
+
 	// Start the server
 	err = appInstance.serve()
 	if err != nil {
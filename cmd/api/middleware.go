@@ -191,6 +191,47 @@ func (a *applicationDependencies) requireActivatedUser(next http.HandlerFunc) ht
 	return a.requireAuthenticatedUser(fn)
 }
 
+// check that the user has been granted a permission
+func (a *applicationDependencies) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := a.contextGetUser(r)
+
+		permissions, err := a.permissionModel.GetAllForUser(user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permissions.Include(code) {
+			a.notPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+
+	// only activated users can hold permissions
+	return a.requireActivatedUser(fn)
+}
+
+// check that the user may add books to the catalog without moderation
+func (a *applicationDependencies) requireDirectPublishing(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		direct, err := a.publishesDirectly(a.contextGetUser(r).ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !direct {
+			a.notPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+
+	return a.requireActivatedUser(fn)
+}
+
 //Step 1: Handles both simple and preflight CORS Request
 
 func (a *applicationDependencies) enableCORS(next http.Handler) http.Handler {                             
@@ -204,10 +245,11 @@ func (a *applicationDependencies) enableCORS(next http.Handler) http.Handler {
 			for i:= range a.config.cors.trustedOrigins {
 				if origin == a.config.cors.trustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Expose-Headers", "ETag, Location")
 					// check if it is a Preflight CORS request
 						if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Allow-Method", "OPTIONS, PUT, PATCH, POST, DELETE")
-		 				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		 				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match")
 						w.WriteHeader(http.StatusOK)
              		 	return
           			}
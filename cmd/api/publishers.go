@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// listPublishersHandler retrieves the publishers, optionally filtered by
+// name. Their ids can be passed to the book search as publisher_id.
+func (a *applicationDependencies) listPublishersHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	name := a.getSingleQueryParameter(queryParameters, "name", "")
+
+	v := validator.New()
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "name")
+	filters.SortSafelist = []string{"id", "name", "-id", "-name"}
+
+	data.ValidateFilters(v, &filters)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	publishers, metadata, err := a.publisherModel.GetAll(name, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"publishers": publishers,
+		"@metadata":  metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// rankedBooksHandler returns a handler listing the books of a ranking, best
+// first. window picks the time window, which defaults to defaultWindow, and
+// genre_slug narrows the list to a genre and its descendants.
+func (a *applicationDependencies) rankedBooksHandler(ranking, defaultWindow string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var filters data.Filters
+		queryParameters := r.URL.Query()
+
+		window := a.getSingleQueryParameter(queryParameters, "window", defaultWindow)
+		genreSlug := a.getSingleQueryParameter(queryParameters, "genre_slug", "")
+
+		v := validator.New()
+		filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+		filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 20, v)
+		filters.Sort = "-score"
+		filters.SortSafelist = []string{"-score"}
+
+		data.ValidateFilters(v, &filters)
+		v.Check(validator.In(window, data.RankingWindows...), "window", "must be week, month, year or all")
+		if !v.Valid() {
+			a.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		books, metadata, err := a.bookModel.Rankings(ranking, window, genreSlug, filters)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		response := envelope{
+			"books":     books,
+			"@metadata": metadata,
+		}
+		err = a.writeJSON(w, http.StatusOK, response, nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+// startRankingRefresh recomputes the book rankings now and then once every
+// refresh interval, so that reading them stays a single indexed query.
+func (a *applicationDependencies) startRankingRefresh() {
+	if a.config.rankings.refreshInterval <= 0 {
+		a.logger.Info("ranking refresh disabled")
+		return
+	}
+
+	a.background(a.refreshRankings)
+
+	go func() {
+		ticker := time.NewTicker(a.config.rankings.refreshInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			a.background(a.refreshRankings)
+		}
+	}()
+}
+
+func (a *applicationDependencies) refreshRankings() {
+	start := time.Now()
+	err := a.bookModel.RefreshRankings()
+	if err != nil {
+		a.logger.Error(err.Error())
+		return
+	}
+	a.logger.Info("refreshed book rankings", "took", time.Since(start).String())
+}
@@ -19,6 +19,8 @@ func (a *applicationDependencies) listReadingListsHandler(w http.ResponseWriter,
 	filters.PageSize = a.getSingleIntegerParameter(r.URL.Query(), "page_size", 10, v)
 	filters.Sort = a.getSingleQueryParameter(r.URL.Query(), "sort", "id")
 	filters.SortSafelist = []string{"id", "name", "-id", "-name"} // Define allowed sort fields
+	a.readCursor(r.URL.Query(), &filters, v)
+	vw := a.readView(r.URL.Query(), readingListShape, v)
 
 	// Validate filters
 	data.ValidateFilters(v, &filters)
@@ -34,9 +36,15 @@ func (a *applicationDependencies) listReadingListsHandler(w http.ResponseWriter,
 		return
 	}
 
+	shaped, err := a.viewReadingLists(vw, lists)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Send response with lists and metadata
 	response := envelope{
-		"reading_lists": lists,
+		"reading_lists": shaped,
 		"metadata":      metadata,
 	}
 	err = a.writeJSON(w, http.StatusOK, response, nil)
@@ -53,6 +61,13 @@ func (a *applicationDependencies) getReadingListHandler(w http.ResponseWriter, r
 		return
 	}
 
+	v := validator.New()
+	vw := a.readView(r.URL.Query(), readingListShape, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	//println(idParam)
 	// Fetch the reading list from the database
 	readingList, err := a.readingListModel.Get(idParam)
@@ -65,8 +80,27 @@ func (a *applicationDependencies) getReadingListHandler(w http.ResponseWriter, r
 		return
 	}
 
+	shaped, err := a.viewReadingList(vw, readingList)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// included books change without a new version of the list
+	response := envelope{"reading_list": shaped}
+	tag, err := representationETag(readingList.Version, response)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if notModified(w, r, tag) {
+		return
+	}
+
 	// Send the reading list in the response
-	err = a.writeJSON(w, http.StatusOK, envelope{"reading_list": readingList}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", tag)
+	err = a.writeJSON(w, http.StatusOK, response, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -143,6 +177,11 @@ func (a *applicationDependencies) updateReadingListHandler(w http.ResponseWriter
 		return
 	}
 
+	if !ifMatch(r, readingList.Version) {
+		a.versionMismatchResponse(w, r, http.StatusPreconditionFailed, "reading_list", readingList, readingList.Version)
+		return
+	}
+
 	// Parse the JSON request body into an input struct
 	var input struct {
 		Name        *string `json:"name"`
@@ -180,18 +219,41 @@ func (a *applicationDependencies) updateReadingListHandler(w http.ResponseWriter
 	// Save the updated reading list to the database
 	err = a.readingListModel.Update(readingList)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConfilct):
+			a.readingListConflictResponse(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	// Send the updated reading list in the response
+	headers := make(http.Header)
+	headers.Set("ETag", etag(readingList.Version))
 	data := envelope{"reading_list": readingList}
-	err = a.writeJSON(w, http.StatusOK, data, nil)
+	err = a.writeJSON(w, http.StatusOK, data, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
+// readingListConflictResponse reloads a reading list that changed while it
+// was being updated and sends the current version back with a 409.
+func (a *applicationDependencies) readingListConflictResponse(w http.ResponseWriter, r *http.Request, id int) {
+	current, err := a.readingListModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.versionMismatchResponse(w, r, http.StatusConflict, "reading_list", current, current.Version)
+}
+
 func (a *applicationDependencies) deleteReadingListHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the reading list ID from the URL.
 	id, err := a.readIDParam(r)
@@ -201,7 +263,7 @@ func (a *applicationDependencies) deleteReadingListHandler(w http.ResponseWriter
 	}
 
 	// Delete the reading list from the database.
-	err = a.readingListModel.Delete(id)
+	err = a.readingListModel.Delete(id, a.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// readRecommendationFilters reads the paging of the recommendation
+// endpoints, which are always ordered by score.
+func (a *applicationDependencies) readRecommendationFilters(r *http.Request, v *validator.Validator) data.Filters {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = "-score"
+	filters.SortSafelist = []string{"-score"}
+
+	data.ValidateFilters(v, &filters)
+	return filters
+}
+
+// similarBooksHandler lists the books liked by the readers who liked this
+// one, leaving out those the user has already reviewed or listed.
+func (a *applicationDependencies) similarBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	filters := a.readRecommendationFilters(r, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.bookModel.BookExists(id)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	books, metadata, err := a.bookModel.Similar(id, a.contextGetUser(r).ID, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"books":     books,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// relatedBooksHandler lists books related to this one. mode=content, the
+// default, compares what the books are about and works for books nobody has
+// reviewed yet, while mode=readers is the same as the similar endpoint.
+func (a *applicationDependencies) relatedBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	mode := a.getSingleQueryParameter(r.URL.Query(), "mode", "content")
+
+	v := validator.New()
+	filters := a.readRecommendationFilters(r, v)
+	v.Check(validator.In(mode, "content", "readers"), "mode", "must be content or readers")
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.bookModel.BookExists(id)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var books any
+	var metadata data.Metadata
+	switch mode {
+	case "readers":
+		books, metadata, err = a.bookModel.Similar(id, a.contextGetUser(r).ID, filters)
+	default:
+		books, metadata, err = a.bookModel.Related(id, filters)
+	}
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"books":     books,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listRecommendationsHandler suggests books to the current user from the
+// books they rated highly or put on their reading lists.
+func (a *applicationDependencies) listRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := a.readRecommendationFilters(r, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	books, metadata, err := a.bookModel.Recommendations(a.contextGetUser(r).ID, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"recommendations": books,
+		"@metadata":       metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// startSimilarityRefresh rebuilds the book similarity table now and then
+// once every refresh interval.
+func (a *applicationDependencies) startSimilarityRefresh() {
+	if a.config.recommendations.refreshInterval <= 0 {
+		a.logger.Info("similarity refresh disabled")
+		return
+	}
+
+	a.background(a.refreshSimilarity)
+
+	go func() {
+		ticker := time.NewTicker(a.config.recommendations.refreshInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			a.background(a.refreshSimilarity)
+		}
+	}()
+}
+
+func (a *applicationDependencies) refreshSimilarity() {
+	start := time.Now()
+	err := a.bookModel.RefreshSimilarity()
+	if err != nil {
+		a.logger.Error(err.Error())
+		return
+	}
+	a.logger.Info("refreshed book similarity", "took", time.Since(start).String())
+}
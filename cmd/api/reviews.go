@@ -96,6 +96,11 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 		return
 	}
 
+	if !ifMatch(r, review.Version) {
+		a.versionMismatchResponse(w, r, http.StatusPreconditionFailed, "review", review, review.Version)
+		return
+	}
+
 	// Define a struct for holding the updated data
 	var input struct {
 		Content *string `json:"review_text"`
@@ -128,18 +133,41 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 	// Save the updated review
 	err = a.reviewModel.Update(review)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConfilct):
+			a.reviewConflictResponse(w, r, id64)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	// Send the updated review in the response
+	headers := make(http.Header)
+	headers.Set("ETag", etag(review.Version))
 	response := envelope{"review": review}
-	err = a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
+// reviewConflictResponse reloads a review that changed while it was being
+// updated and sends the current version back with a 409.
+func (a *applicationDependencies) reviewConflictResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	current, err := a.reviewModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecord):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.versionMismatchResponse(w, r, http.StatusConflict, "review", current, current.Version)
+}
+
 func (a *applicationDependencies) deleteReviewHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse the review ID from the URL and convert it to int64
 	revID, err := a.readIDParam(r)
@@ -152,7 +180,7 @@ func (a *applicationDependencies) deleteReviewHandler(w http.ResponseWriter, r *
 	id64 := int64(revID)
 
 	// Delete the review
-	err = a.reviewModel.Delete(id64)
+	err = a.reviewModel.Delete(id64, a.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -182,6 +210,13 @@ func (a *applicationDependencies) listReviewsHandler(w http.ResponseWriter, r *h
 		return
 	}
 
+	v := validator.New()
+	vw := a.readView(r.URL.Query(), reviewShape, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	err = a.bookModel.BookExists(bookID)
 	if err != nil {
 		a.notFoundResponse(w, r)
@@ -194,8 +229,14 @@ func (a *applicationDependencies) listReviewsHandler(w http.ResponseWriter, r *h
 		return
 	}
 
+	shaped, err := a.viewReviews(vw, reviews)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	response := envelope{
-		"reviews": reviews,
+		"reviews": shaped,
 	}
 	err = a.writeJSON(w, http.StatusOK, response, nil)
 	if err != nil {
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// listBookRevisionsHandler returns the edit history of a book, newest first,
+// with the fields each revision changed.
+func (a *applicationDependencies) listBookRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 20, v)
+	filters.Sort = "-revision"
+	filters.SortSafelist = []string{"-revision"}
+
+	data.ValidateFilters(v, &filters)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.bookModel.BookExists(id)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	revisions, metadata, err := a.bookModel.Revisions(id, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"revisions": revisions,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertBookHandler puts a book back the way an earlier revision left it.
+// The revert is saved as a new revision, so it can be undone in turn.
+func (a *applicationDependencies) revertBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	rev, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("rev"))
+	if err != nil || rev < 1 {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	book, err := a.bookModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !ifMatch(r, book.Version) {
+		a.versionMismatchResponse(w, r, http.StatusPreconditionFailed, "book", book, book.Version)
+		return
+	}
+
+	revision, err := a.bookModel.Revision(id, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = revision.State.ApplyTo(book)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// genres deleted since the revision are reported through v
+	v := validator.New()
+	book.Genres = []data.BookGenre{}
+	if len(revision.State.Genres) > 0 {
+		err = a.setBookGenres(book, revision.State.Genres, v)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	data.ValidateBook(v, book)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.bookModel.Revert(book, a.contextGetUser(r).ID, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConfilct):
+			a.bookConflictResponse(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(book.Version))
+	err = a.writeJSON(w, http.StatusOK, envelope{"book": book}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
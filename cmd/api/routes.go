@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/RayMC17/bookclub-api/internal/data"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -15,12 +16,77 @@ func (a *applicationDependencies) routes() http.Handler {
 
 	// Books routes
 	router.HandlerFunc(http.MethodGet, "/api/v1/books", a.requireActivatedUser(a.listBooksHandler))         //done
-	router.HandlerFunc(http.MethodGet, "/api/v1/books/:id", a.requireActivatedUser(a.getBookHandler))       //done
 	router.HandlerFunc(http.MethodPost, "/api/v1/books", a.requireActivatedUser(a.createBookHandler))       //done
 	router.HandlerFunc(http.MethodPut, "/api/v1/books/:id", a.requireActivatedUser(a.updateBookHandler))    //done
 	router.HandlerFunc(http.MethodDelete, "/api/v1/books/:id", a.requireActivatedUser(a.deleteBookHandler)) //done
 	router.HandlerFunc(http.MethodGet, "/api/v1/book/search", a.requireActivatedUser(a.searchBookHandler))  //done
 
+	// Collection-level book actions that share the /api/v1/books/:id slot
+	bookGetActions := map[string]http.HandlerFunc{
+		"export":     a.requireActivatedUser(a.exportBooksHandler),
+		"suggest":    a.requireActivatedUser(a.suggestBooksHandler),
+		"duplicates": a.requirePermission(data.PermissionCatalogAdmin, a.listDuplicatesHandler),
+		"top":        a.requireActivatedUser(a.rankedBooksHandler(data.RankingTop, "all")),
+		"trending":   a.requireActivatedUser(a.rankedBooksHandler(data.RankingTrending, "week")),
+	}
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:id", a.namedActions(bookGetActions, a.requireActivatedUser(a.getBookHandler)))
+	bookPostActions := map[string]http.HandlerFunc{
+		// imports skip the moderation queue
+		"import": a.requireDirectPublishing(a.importBooksHandler),
+	}
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:id", a.namedActions(bookPostActions, a.notFoundResponse))
+	router.HandlerFunc(http.MethodGet, "/api/v1/imports/:id", a.requireActivatedUser(a.getImportHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:id/enrich", a.requireActivatedUser(a.enrichBookHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/books/:id/cover", a.requireActivatedUser(a.uploadCoverHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/covers/:name", a.serveCoverHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:id/restore", a.requireActivatedUser(a.restoreHandler(data.TrashBook)))
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:id/split", a.requirePermission(data.PermissionCatalogAdmin, a.splitEditionHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:id/merge", a.requirePermission(data.PermissionCatalogAdmin, a.mergeBookHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:id/revisions", a.requireActivatedUser(a.listBookRevisionsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:id/similar", a.requireActivatedUser(a.similarBooksHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:id/related", a.requireActivatedUser(a.relatedBooksHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/books/:id/tags", a.requireActivatedUser(a.listBookTagsHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/books/:id/tags/:tag", a.requireActivatedUser(a.tagBookHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/books/:id/tags/:tag", a.requireActivatedUser(a.untagBookHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/books/:id/revisions/:rev/revert", a.requireActivatedUser(a.revertBookHandler))
+
+	// Works routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/works/:id", a.requireActivatedUser(a.getWorkHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/works/:id/editions", a.requireActivatedUser(a.listWorkEditionsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/works/:id/editions", a.requirePermission(data.PermissionCatalogAdmin, a.mergeEditionsHandler))
+
+	// Series routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/series", a.requireActivatedUser(a.listSeriesHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/series/:id", a.requireActivatedUser(a.getSeriesHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/series", a.requirePermission(data.PermissionCatalogAdmin, a.createSeriesHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/series/:id", a.requirePermission(data.PermissionCatalogAdmin, a.updateSeriesHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/series/:id", a.requirePermission(data.PermissionCatalogAdmin, a.deleteSeriesHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/series/:id/books", a.requireActivatedUser(a.listSeriesBooksHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/series/:id/books", a.requirePermission(data.PermissionCatalogAdmin, a.setSeriesBookHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/series/:id/books", a.requirePermission(data.PermissionCatalogAdmin, a.removeSeriesBookHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/series/:id/next", a.requireActivatedUser(a.nextInSeriesHandler))
+
+	// Authors routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/authors", a.requireActivatedUser(a.listAuthorsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/authors/:id", a.requireActivatedUser(a.getAuthorHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/authors/:id/books", a.requireActivatedUser(a.listAuthorBooksHandler))
+
+	// Publisher routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/publishers", a.requireActivatedUser(a.listPublishersHandler))
+
+	// Submission routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/submissions", a.requireActivatedUser(a.listSubmissionsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/submissions/:id", a.requireActivatedUser(a.getSubmissionHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/submissions/:id/approve", a.requirePermission(data.PermissionModerateBooks, a.approveSubmissionHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/submissions/:id/reject", a.requirePermission(data.PermissionModerateBooks, a.rejectSubmissionHandler))
+
+	// Genres routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/genres", a.requireActivatedUser(a.listGenresHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/genres/:id", a.requireActivatedUser(a.getGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/genres", a.requirePermission(data.PermissionCatalogAdmin, a.createGenreHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/genres/:id", a.requirePermission(data.PermissionCatalogAdmin, a.updateGenreHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/genres/:id", a.requirePermission(data.PermissionCatalogAdmin, a.deleteGenreHandler))
+
 	// Reading Lists routes
 	router.HandlerFunc(http.MethodGet, "/api/v1/lists", a.requireActivatedUser(a.listReadingListsHandler))                       //done
 	router.HandlerFunc(http.MethodGet, "/api/v1/lists/:id", a.requireActivatedUser(a.getReadingListHandler))                     //done
@@ -29,12 +95,25 @@ func (a *applicationDependencies) routes() http.Handler {
 	router.HandlerFunc(http.MethodDelete, "/api/v1/lists/:id", a.requireActivatedUser(a.deleteReadingListHandler))               //done
 	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:id/books", a.requireActivatedUser(a.addBookToReadingListHandler))        //done
 	router.HandlerFunc(http.MethodDelete, "/api/v1/lists/:id/books", a.requireActivatedUser(a.removeBookFromReadingListHandler)) //done
+	router.HandlerFunc(http.MethodPost, "/api/v1/lists/:id/restore", a.requireActivatedUser(a.restoreHandler(data.TrashList)))
 
 	// Reviews routes
 	router.HandlerFunc(http.MethodGet, "/api/v1/books/:id/reviews", a.requireActivatedUser(a.listReviewsHandler))   //done
 	router.HandlerFunc(http.MethodPost, "/api/v1/books/:id/reviews", a.requireActivatedUser(a.createReviewHandler)) //done
 	router.HandlerFunc(http.MethodPut, "/api/v1/reviews/:id", a.requireActivatedUser(a.updateReviewHandler))        //done
 	router.HandlerFunc(http.MethodDelete, "/api/v1/reviews/:id", a.requireActivatedUser(a.deleteReviewHandler))     //done
+	router.HandlerFunc(http.MethodPost, "/api/v1/reviews/:id/restore", a.requireActivatedUser(a.restoreHandler(data.TrashReview)))
+
+	// Trash routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/trash", a.requireActivatedUser(a.listTrashHandler))
+
+	// Tag routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/tags", a.requireActivatedUser(a.tagCloudHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/tags/:tag/books", a.requireActivatedUser(a.listTaggedBooksHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/me/tags", a.requireActivatedUser(a.listMyTagsHandler))
+
+	// Recommendation routes
+	router.HandlerFunc(http.MethodGet, "/api/v1/me/recommendations", a.requireActivatedUser(a.listRecommendationsHandler))
 
 	// Users routes
 	router.HandlerFunc(http.MethodPost, "/api/v1/user", a.makeUserProfileHandler)                                       //done
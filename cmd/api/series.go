@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+func (a *applicationDependencies) listSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	name := a.getSingleQueryParameter(queryParameters, "name", "")
+
+	v := validator.New()
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "name")
+	filters.SortSafelist = []string{"id", "name", "-id", "-name"}
+
+	data.ValidateFilters(v, &filters)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	series, metadata, err := a.seriesModel.GetAll(name, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"series":    series,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) getSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	series, ok := a.readSeries(w, r)
+	if !ok {
+		return
+	}
+
+	if notModified(w, r, etag(series.Version)) {
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(series.Version))
+	err := a.writeJSON(w, http.StatusOK, envelope{"series": series}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) createSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	series := &data.Series{
+		Name:        input.Name,
+		Description: input.Description,
+	}
+
+	v := validator.New()
+	data.ValidateSeries(v, series)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.seriesModel.Insert(series)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/series/%d", series.ID))
+	err = a.writeJSON(w, http.StatusCreated, envelope{"series": series}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) updateSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	series, ok := a.readSeries(w, r)
+	if !ok {
+		return
+	}
+
+	if !ifMatch(r, series.Version) {
+		a.versionMismatchResponse(w, r, http.StatusPreconditionFailed, "series", series, series.Version)
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		series.Name = *input.Name
+	}
+	if input.Description != nil {
+		series.Description = *input.Description
+	}
+
+	v := validator.New()
+	data.ValidateSeries(v, series)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.seriesModel.Update(series)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConfilct):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(series.Version))
+	err = a.writeJSON(w, http.StatusOK, envelope{"series": series}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) deleteSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.seriesModel.Delete(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "series successfully deleted"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listSeriesBooksHandler returns the books of a series in reading order.
+func (a *applicationDependencies) listSeriesBooksHandler(w http.ResponseWriter, r *http.Request) {
+	series, ok := a.readSeries(w, r)
+	if !ok {
+		return
+	}
+
+	books, err := a.seriesModel.GetBooks(series.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"series": series, "books": books}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// setSeriesBookHandler adds a book to a series or moves it to a new
+// position.
+func (a *applicationDependencies) setSeriesBookHandler(w http.ResponseWriter, r *http.Request) {
+	series, ok := a.readSeries(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		BookID   int64    `json:"book_id"`
+		Position *float64 `json:"position"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.BookID > 0, "book_id", "must be provided")
+	v.Check(input.Position != nil, "position", "must be provided")
+	if input.Position != nil {
+		data.ValidateSeriesPosition(v, *input.Position)
+	}
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.seriesModel.SetBook(series.ID, input.BookID, *input.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("book_id", "must be an existing book")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	books, err := a.seriesModel.GetBooks(series.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"books": books}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) removeSeriesBookHandler(w http.ResponseWriter, r *http.Request) {
+	series, ok := a.readSeries(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		BookID int64 `json:"book_id"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = a.seriesModel.RemoveBook(series.ID, input.BookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "book successfully removed from the series"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// nextInSeriesHandler returns the first book of the series the current user
+// has not finished, going by the books marked completed on their reading
+// lists.
+func (a *applicationDependencies) nextInSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	series, ok := a.readSeries(w, r)
+	if !ok {
+		return
+	}
+
+	next, err := a.seriesModel.NextUnread(series.ID, a.contextGetUser(r).ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{"series": series, "next": next}
+	if next == nil {
+		response["message"] = "you have read every book in this series"
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// readSeries loads the series named in the URL, sending a 404 when there is
+// none.
+func (a *applicationDependencies) readSeries(w http.ResponseWriter, r *http.Request) (*data.Series, bool) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	series, err := a.seriesModel.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return series, true
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// publishesDirectly reports whether books from the user go straight into
+// the catalog rather than the moderation queue.
+func (a *applicationDependencies) publishesDirectly(userID int) (bool, error) {
+	permissions, err := a.permissionModel.GetAllForUser(userID)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Include(data.PermissionCatalogAdmin) || permissions.Include(data.PermissionModerateBooks), nil
+}
+
+// submitBook queues a validated book from a regular member for moderation
+// instead of adding it to the catalog.
+func (a *applicationDependencies) submitBook(w http.ResponseWriter, r *http.Request, user *data.User, book *data.Book) {
+	sub := &data.BookSubmission{
+		SubmittedBy: user.ID,
+		WorkID:      book.WorkID,
+		Book:        book.State(),
+	}
+
+	err := a.submissionModel.Insert(sub)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/submissions/%d", sub.ID))
+	response := envelope{
+		"submission": sub,
+		"message":    "your book has been submitted and will appear in the catalog once a moderator approves it",
+	}
+	err = a.writeJSON(w, http.StatusAccepted, response, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listSubmissionsHandler is the moderation queue. Moderators see every
+// submission, other members only their own. Pending submissions are listed
+// oldest first unless asked otherwise.
+func (a *applicationDependencies) listSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	status := a.getSingleQueryParameter(queryParameters, "status", data.SubmissionPending)
+
+	v := validator.New()
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 20, v)
+	filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "created_at")
+	filters.SortSafelist = []string{"created_at", "-created_at", "moderated_at", "-moderated_at"}
+
+	data.ValidateFilters(v, &filters)
+	v.Check(validator.In(status, data.SubmissionStatuses...), "status", "must be pending, approved or rejected")
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := a.contextGetUser(r)
+	permissions, err := a.permissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	submittedBy := user.ID
+	if permissions.Include(data.PermissionModerateBooks) {
+		submittedBy = 0
+	}
+
+	submissions, metadata, err := a.submissionModel.GetAll(status, submittedBy, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"submissions": submissions,
+		"@metadata":   metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// getSubmissionHandler shows a submission to the member who sent it and to
+// moderators.
+func (a *applicationDependencies) getSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := a.readSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	user := a.contextGetUser(r)
+	if sub.SubmittedBy != user.ID {
+		permissions, err := a.permissionModel.GetAllForUser(user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		// other members' submissions are not theirs to know about
+		if !permissions.Include(data.PermissionModerateBooks) {
+			a.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(sub.Version))
+	err := a.writeJSON(w, http.StatusOK, envelope{"submission": sub}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// approveSubmissionHandler adds a pending submission to the catalog. The
+// body is optional and takes the same fields as a book update, so the
+// moderator can fix the book up while approving it.
+func (a *applicationDependencies) approveSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := a.readPendingSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	var edits bookEdits
+	if r.ContentLength != 0 {
+		err := a.readJSON(w, r, &edits)
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	book := &data.Book{WorkID: sub.WorkID}
+	err := sub.Book.ApplyTo(book)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// genres deleted since the submission are reported through v
+	v := validator.New()
+	book.Genres = []data.BookGenre{}
+	if edits.Genres == nil && len(sub.Book.Genres) > 0 {
+		edits.Genres = &sub.Book.Genres
+	}
+	err = a.applyBookEdits(book, edits, v)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	data.ValidateBook(v, book)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.submissionModel.Approve(sub, book, a.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownWork):
+			v.AddError("work_id", "does not exist")
+			a.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateISBN):
+			v.AddError("isbn", "a book with this isbn is already in the catalog")
+			a.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConfilct):
+			a.submissionConflictResponse(w, r, sub.ID)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	a.notifySubmitter(sub, "submission_approved.tmpl")
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/books/%d", book.ID))
+	err = a.writeJSON(w, http.StatusCreated, envelope{"submission": sub, "book": book}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// rejectSubmissionHandler keeps a pending submission out of the catalog.
+// The reason is passed on to the member who sent it.
+func (a *applicationDependencies) rejectSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	sub, ok := a.readPendingSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	var incomingData struct {
+		Reason string `json:"reason"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateRejection(v, incomingData.Reason)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.submissionModel.Reject(sub, a.contextGetUser(r).ID, incomingData.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConfilct):
+			a.submissionConflictResponse(w, r, sub.ID)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	a.notifySubmitter(sub, "submission_rejected.tmpl")
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(sub.Version))
+	err = a.writeJSON(w, http.StatusOK, envelope{"submission": sub}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// readSubmission loads the submission named in the URL. A response has
+// been sent when ok is false.
+func (a *applicationDependencies) readSubmission(w http.ResponseWriter, r *http.Request) (*data.BookSubmission, bool) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	sub, err := a.submissionModel.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return sub, true
+}
+
+// readPendingSubmission loads the submission named in the URL for a
+// moderator's decision, which can only be made once.
+func (a *applicationDependencies) readPendingSubmission(w http.ResponseWriter, r *http.Request) (*data.BookSubmission, bool) {
+	sub, ok := a.readSubmission(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !ifMatch(r, sub.Version) {
+		a.versionMismatchResponse(w, r, http.StatusPreconditionFailed, "submission", sub, sub.Version)
+		return nil, false
+	}
+
+	if sub.Status != data.SubmissionPending {
+		a.errorResponseJSON(w, r, http.StatusConflict, fmt.Sprintf("the submission has already been %s", sub.Status))
+		return nil, false
+	}
+	return sub, true
+}
+
+// submissionConflictResponse reloads a submission that another moderator
+// dealt with in the meantime and sends it back with a 409.
+func (a *applicationDependencies) submissionConflictResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	current, err := a.submissionModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.versionMismatchResponse(w, r, http.StatusConflict, "submission", current, current.Version)
+}
+
+// notifySubmitter emails the outcome of the moderation to the member who
+// sent the submission.
+func (a *applicationDependencies) notifySubmitter(sub *data.BookSubmission, templateFile string) {
+	a.background(func() {
+		user, err := a.userModel.Get(sub.SubmittedBy)
+		if err != nil {
+			a.logger.Error(err.Error())
+			return
+		}
+
+		data := map[string]any{
+			"submissionID": sub.ID,
+			"title":        sub.Book.Title,
+			"reason":       sub.Reason,
+		}
+		if sub.BookID != nil {
+			data["bookID"] = *sub.BookID
+		}
+		err = a.mailer.Send(user.Email, templateFile, data)
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	})
+}
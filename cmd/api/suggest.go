@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// suggestBooksHandler returns typeahead suggestions of book titles and
+// author names for the search box.
+func (a *applicationDependencies) suggestBooksHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+	v := validator.New()
+
+	q := strings.TrimSpace(a.getSingleQueryParameter(queryParameters, "q", ""))
+	limit := a.getSingleIntegerParameter(queryParameters, "limit", 8, v)
+
+	data.ValidateSuggest(v, q, limit)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suggestions, err := a.bookModel.Suggest(q, limit)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
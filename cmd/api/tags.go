@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// readTagParam reads the tag in the URL in its stored form.
+func (a *applicationDependencies) readTagParam(r *http.Request, v *validator.Validator) string {
+	tag := data.NormalizeTag(httprouter.ParamsFromContext(r.Context()).ByName("tag"))
+	data.ValidateTag(v, tag)
+	return tag
+}
+
+// writeBookTags responds with the tags on a book as the current user sees
+// them.
+func (a *applicationDependencies) writeBookTags(w http.ResponseWriter, r *http.Request, bookID int) {
+	tags, err := a.tagModel.ForBook(bookID, a.contextGetUser(r).ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listBookTagsHandler returns the tags members have put on a book, with the
+// current user's own.
+func (a *applicationDependencies) listBookTagsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.bookModel.BookExists(id)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	a.writeBookTags(w, r, id)
+}
+
+// tagBookHandler puts one of the current user's tags on a book.
+func (a *applicationDependencies) tagBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	tag := a.readTagParam(r, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.bookModel.BookExists(id)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.tagModel.Add(a.contextGetUser(r).ID, id, tag)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	a.writeBookTags(w, r, id)
+}
+
+// untagBookHandler takes one of the current user's tags off a book.
+func (a *applicationDependencies) untagBookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	tag := a.readTagParam(r, v)
+	if !v.Valid() {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.tagModel.Remove(a.contextGetUser(r).ID, id, tag)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	a.writeBookTags(w, r, id)
+}
+
+// tagCloudHandler returns the most used tags across all members.
+func (a *applicationDependencies) tagCloudHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeTagCloud(w, r, 0)
+}
+
+// listMyTagsHandler returns the current user's tags, their personal shelves.
+func (a *applicationDependencies) listMyTagsHandler(w http.ResponseWriter, r *http.Request) {
+	a.writeTagCloud(w, r, a.contextGetUser(r).ID)
+}
+
+func (a *applicationDependencies) writeTagCloud(w http.ResponseWriter, r *http.Request, userID int) {
+	v := validator.New()
+	limit := a.getSingleIntegerParameter(r.URL.Query(), "limit", 50, v)
+	v.Check(limit > 0 && limit <= 500, "limit", "must be between 1 and 500")
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tags, err := a.tagModel.Cloud(userID, limit)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listTaggedBooksHandler lists the books carrying a tag. mine=true limits
+// them to the books the current user tagged.
+func (a *applicationDependencies) listTaggedBooksHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	tag := a.readTagParam(r, v)
+	mine := a.getSingleBooleanParameter(queryParameters, "mine", false, v)
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 20, v)
+	filters.Sort = "-taggers"
+	filters.SortSafelist = []string{"-taggers"}
+
+	data.ValidateFilters(v, &filters)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userID := 0
+	if mine {
+		userID = a.contextGetUser(r).ID
+	}
+
+	books, metadata, err := a.tagModel.Books(tag, userID, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"books":     books,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
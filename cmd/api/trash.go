@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// listTrashHandler lists the soft deleted books, reading lists and reviews.
+// Catalog admins see everything, other users what they deleted or own.
+func (a *applicationDependencies) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.Filters
+	queryParameters := r.URL.Query()
+
+	kind := a.getSingleQueryParameter(queryParameters, "type", "")
+
+	v := validator.New()
+	filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
+	filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
+	filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "-deleted_at")
+	filters.SortSafelist = []string{"deleted_at", "-deleted_at"}
+
+	data.ValidateFilters(v, &filters)
+	v.Check(kind == "" || validator.In(kind, data.TrashKinds...), "type", "must be book, list or review")
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := a.contextGetUser(r)
+	permissions, err := a.permissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	items, metadata, err := a.trashModel.GetAll(user.ID, permissions.Include(data.PermissionCatalogAdmin), kind, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"trash":     items,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreHandler returns a handler that takes a record of the given kind out
+// of the trash. Whoever deleted it, its owner and catalog admins may do so.
+func (a *applicationDependencies) restoreHandler(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := a.readIDParam(r)
+		if err != nil {
+			a.notFoundResponse(w, r)
+			return
+		}
+
+		item, err := a.trashModel.Get(kind, int64(id))
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				a.notFoundResponse(w, r)
+			default:
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		user := a.contextGetUser(r)
+		allowed := (item.DeletedBy != nil && *item.DeletedBy == user.ID) || (item.OwnerID != nil && *item.OwnerID == user.ID)
+		if !allowed {
+			permissions, err := a.permissionModel.GetAllForUser(user.ID)
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+			if !permissions.Include(data.PermissionCatalogAdmin) {
+				a.notPermittedResponse(w, r)
+				return
+			}
+		}
+
+		err = a.trashModel.Restore(kind, int64(id))
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				a.notFoundResponse(w, r)
+			case errors.Is(err, data.ErrRestoreConflict):
+				a.errorResponseJSON(w, r, http.StatusConflict, "another book with the same isbn has been added since this one was deleted")
+			default:
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		var response envelope
+		switch kind {
+		case data.TrashBook:
+			book, err := a.bookModel.Get(id)
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+			response = envelope{"book": book}
+		case data.TrashList:
+			readingList, err := a.readingListModel.Get(id)
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+			response = envelope{"reading_list": readingList}
+		default:
+			review, err := a.reviewModel.Get(int64(id))
+			switch {
+			// a review of a book that is still in the trash stays hidden
+			case errors.Is(err, data.ErrNoRecord):
+				response = envelope{"message": "review restored, its book is still in the trash"}
+			case err != nil:
+				a.serverErrorResponse(w, r, err)
+				return
+			default:
+				response = envelope{"review": review}
+			}
+		}
+
+		err = a.writeJSON(w, http.StatusOK, response, nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+// startTrashPurge permanently deletes trashed records once they are older
+// than the retention window, checking every purge interval.
+func (a *applicationDependencies) startTrashPurge() {
+	if a.config.trash.retention <= 0 || a.config.trash.purgeInterval <= 0 {
+		a.logger.Info("trash purge disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(a.config.trash.purgeInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			a.background(a.purgeTrash)
+		}
+	}()
+}
+
+func (a *applicationDependencies) purgeTrash() {
+	result, err := a.trashModel.Purge(time.Now().Add(-a.config.trash.retention))
+	if err != nil {
+		a.logger.Error(err.Error())
+		return
+	}
+
+	for _, key := range result.CoverKeys {
+		a.removeCover(key)
+	}
+
+	if result.Books+result.Lists+result.Reviews > 0 {
+		a.logger.Info("purged trash", "books", result.Books, "lists", result.Lists, "reviews", result.Reviews,
+			"retention", a.config.trash.retention.String())
+	}
+}
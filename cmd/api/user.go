@@ -94,6 +94,13 @@ func (a *applicationDependencies) getUserProfileHandler(w http.ResponseWriter, r
 		return
 	}
 
+	v := validator.New()
+	vw := a.readView(r.URL.Query(), userShape, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	// Get the user profile from the database using the user model
 	profile, err := a.userModel.Get(id)
 	if err != nil {
@@ -106,8 +113,14 @@ func (a *applicationDependencies) getUserProfileHandler(w http.ResponseWriter, r
 		return
 	}
 
+	shaped, err := a.viewUser(vw, profile)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Respond with the user profile data in JSON format
-	err = a.writeJSON(w, http.StatusOK, envelope{"user_profile": profile}, nil)
+	err = a.writeJSON(w, http.StatusOK, envelope{"user_profile": shaped}, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -121,6 +134,13 @@ func (a *applicationDependencies) getUserReadingListsHandler(w http.ResponseWrit
 		return
 	}
 
+	v := validator.New()
+	vw := a.readView(r.URL.Query(), readingListShape, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	// Get the reading lists associated with the user from the model
 	readingLists, err := a.readingListModel.GetAllByUser(int64(userID))
 	if err != nil {
@@ -128,9 +148,15 @@ func (a *applicationDependencies) getUserReadingListsHandler(w http.ResponseWrit
 		return
 	}
 
+	shaped, err := a.viewReadingLists(vw, readingLists)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Respond with the reading lists and metadata in JSON format
 	response := envelope{
-		"reading_lists": readingLists,
+		"reading_lists": shaped,
 	}
 	err = a.writeJSON(w, http.StatusOK, response, nil)
 	if err != nil {
@@ -146,6 +172,13 @@ func (a *applicationDependencies) getUserReviewsHandler(w http.ResponseWriter, r
 		return
 	}
 
+	v := validator.New()
+	vw := a.readView(r.URL.Query(), reviewShape, v)
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
 	// Get the reviews associated with the user from the model
 	reviews, err := a.reviewModel.GetAllByUser(int64(id))
 	if err != nil {
@@ -153,9 +186,15 @@ func (a *applicationDependencies) getUserReviewsHandler(w http.ResponseWriter, r
 		return
 	}
 
+	shaped, err := a.viewReviews(vw, reviews)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Respond with the reviews and metadata in JSON format
 	response := envelope{
-		"reviews": reviews,
+		"reviews": shaped,
 	}
 	err = a.writeJSON(w, http.StatusOK, response, nil)
 	if err != nil {
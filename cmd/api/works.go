@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// getWorkHandler retrieves a work with the review aggregates across all of
+// its editions.
+func (a *applicationDependencies) getWorkHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	work, err := a.workModel.Get(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"work": work}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listWorkEditionsHandler retrieves the editions of a work, each with the
+// review aggregates of that edition.
+func (a *applicationDependencies) listWorkEditionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	editions, err := a.workModel.GetEditions(int64(id))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if len(editions) == 0 {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"editions": editions}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// mergeEditionsHandler moves existing books under a work as editions of it.
+func (a *applicationDependencies) mergeEditionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		BookIDs []int64 `json:"book_ids"`
+	}
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	slices.Sort(input.BookIDs)
+	input.BookIDs = slices.Compact(input.BookIDs)
+
+	v := validator.New()
+	v.Check(len(input.BookIDs) > 0, "book_ids", "must contain at least one book")
+	v.Check(len(input.BookIDs) <= 100, "book_ids", "must not contain more than 100 books")
+	if !v.Valid() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.workModel.MergeEditions(int64(id), input.BookIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	editions, err := a.workModel.GetEditions(int64(id))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"editions": editions}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// splitEditionHandler moves a book out of its work into a new work of its
+// own.
+func (a *applicationDependencies) splitEditionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	work, err := a.workModel.SplitEdition(int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/works/%d", work.ID))
+	err = a.writeJSON(w, http.StatusCreated, envelope{"work": work}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -9,6 +9,7 @@ require (
 
 require (
 	github.com/go-mail/mail/v2 v2.3.0
+	golang.org/x/image v0.20.0
 	golang.org/x/time v0.7.0
 )
 
@@ -0,0 +1,257 @@
+// Package covers turns uploaded book cover images into a cleaned original and
+// a set of thumbnails. Every file is named after the SHA-256 of the cleaned
+// original, so a name never points at different bytes and can be cached
+// forever.
+package covers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"regexp"
+	"strings"
+
+	// registers the WebP decoder with the image package
+	_ "golang.org/x/image/webp"
+)
+
+// URLPrefix is where the API serves the stored cover files from.
+const URLPrefix = "/api/v1/covers/"
+
+const (
+	// MaxBytes is the largest upload accepted.
+	MaxBytes = 5 << 20 // 5 MB
+	// maxPixels guards against small files that decode into huge images.
+	maxPixels   = 6000 * 6000
+	jpegQuality = 85
+)
+
+var (
+	ErrUnsupportedType = errors.New("cover must be a JPEG, PNG or WebP image")
+	ErrTypeMismatch    = errors.New("cover content does not match its content type")
+	ErrInvalidImage    = errors.New("cover image could not be decoded")
+	ErrTooManyPixels   = errors.New("cover image dimensions are too large")
+)
+
+// Size is a thumbnail width. Thumbnails keep the aspect ratio of the
+// original and are never scaled up.
+type Size struct {
+	Name  string
+	Width int
+}
+
+var Sizes = []Size{
+	{Name: "small", Width: 96},
+	{Name: "medium", Width: 256},
+	{Name: "large", Width: 512},
+}
+
+// extensions maps the accepted content types to the file extension used for
+// the stored files.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// contentTypes is the reverse of extensions.
+var contentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+}
+
+var nameRX = regexp.MustCompile(`^[0-9a-f]{64}(-[a-z]+)?\.(jpg|png|webp)$`)
+
+// File is one stored image.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// URLs are the public locations of a book's cover and its thumbnails.
+type URLs struct {
+	Original string `json:"original"`
+	Small    string `json:"small"`
+	Medium   string `json:"medium"`
+	Large    string `json:"large"`
+}
+
+// Process checks that data really is an image of the declared content type,
+// strips any embedded metadata and returns the cleaned original followed by
+// its thumbnails. The name of the first file is the key to store on the book.
+func Process(data []byte, contentType string) ([]File, error) {
+	ext, ok := extensions[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+	if http.DetectContentType(data) != contentType {
+		return nil, ErrTypeMismatch
+	}
+
+	img, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var clean []byte
+	if ext == ".webp" {
+		// there is no WebP encoder, so WebP covers are cleaned at the
+		// container level instead
+		clean, err = stripWebP(data)
+	} else {
+		// re-encoding keeps the pixels and drops EXIF, XMP and text chunks
+		clean, err = encode(img, ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	hash := contentHash(clean)
+
+	thumbs, err := thumbnails(img, hash, ext)
+	if err != nil {
+		return nil, err
+	}
+	return append([]File{{Name: hash + ext, ContentType: contentType, Data: clean}}, thumbs...), nil
+}
+
+// Names lists every file stored for a cover key.
+func Names(key string) []string {
+	names := []string{key}
+	hash, ext, ok := splitKey(key)
+	if !ok {
+		return names
+	}
+	for _, size := range Sizes {
+		names = append(names, hash+"-"+size.Name+thumbExt(ext))
+	}
+	return names
+}
+
+// URLsFor returns the cover URLs for a key, or nil when there is no cover.
+func URLsFor(key string) *URLs {
+	hash, ext, ok := splitKey(key)
+	if !ok {
+		return nil
+	}
+	thumb := thumbExt(ext)
+	return &URLs{
+		Original: URLPrefix + key,
+		Small:    URLPrefix + hash + "-small" + thumb,
+		Medium:   URLPrefix + hash + "-medium" + thumb,
+		Large:    URLPrefix + hash + "-large" + thumb,
+	}
+}
+
+// ContentType returns the content type of a stored file name, or "" when
+// the name could not have been produced by Process.
+func ContentType(name string) string {
+	if !nameRX.MatchString(name) {
+		return ""
+	}
+	return contentTypes[name[strings.LastIndexByte(name, '.'):]]
+}
+
+func splitKey(key string) (hash, ext string, ok bool) {
+	if !nameRX.MatchString(key) || strings.Contains(key, "-") {
+		return "", "", false
+	}
+	dot := strings.LastIndexByte(key, '.')
+	return key[:dot], key[dot:], true
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// thumbExt is the extension of the thumbnails of a cover. WebP thumbnails
+// are JPEGs since there is no WebP encoder.
+func thumbExt(ext string) string {
+	if ext == ".webp" {
+		return ".jpg"
+	}
+	return ext
+}
+
+// decode checks the size of an image before decoding it.
+func decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	return img, nil
+}
+
+func thumbnails(img image.Image, hash, ext string) ([]File, error) {
+	ext = thumbExt(ext)
+	files := []File{}
+	for _, size := range Sizes {
+		thumb, err := encode(resize(img, size.Width), ext)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: hash + "-" + size.Name + ext, ContentType: contentTypes[ext], Data: thumb})
+	}
+	return files, nil
+}
+
+func encode(img image.Image, ext string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch ext {
+	case ".png":
+		err = png.Encode(&buf, img)
+	default:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return buf.Bytes(), err
+}
+
+// resize scales img down to the given width by averaging the source pixels
+// that fall into each destination pixel.
+func resize(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= width {
+		return img
+	}
+	height := max(1, b.Dy()*width/b.Dx())
+
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy0 := b.Min.Y + y*b.Dy()/height
+		sy1 := max(sy0+1, b.Min.Y+(y+1)*b.Dy()/height)
+		for x := 0; x < width; x++ {
+			sx0 := b.Min.X + x*b.Dx()/width
+			sx1 := max(sx0+1, b.Min.X+(x+1)*b.Dx()/width)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,104 @@
+package covers
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrFileNotFound = errors.New("cover file not found")
+
+// Storage keeps the cover files. Names are always ones produced by Process.
+type Storage interface {
+	Put(name string, data []byte) error
+	Open(name string) (io.ReadSeekCloser, time.Time, error)
+	Delete(name string) error
+}
+
+// LocalStorage stores the cover files in a directory on the local
+// filesystem.
+type LocalStorage struct {
+	Dir string
+}
+
+// NewLocalStorage creates the directory if needed.
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStorage{Dir: dir}, nil
+}
+
+// Put writes the file under a temporary name first so readers never see a
+// partly written image.
+func (s *LocalStorage) Put(name string, data []byte) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	// the same content always gets the same name
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStorage) Open(name string) (io.ReadSeekCloser, time.Time, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, time.Time{}, ErrFileNotFound
+		}
+		return nil, time.Time{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, time.Time{}, err
+	}
+	return f, info.ModTime(), nil
+}
+
+func (s *LocalStorage) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// path rejects anything that is not a cover file name, which also keeps
+// requests from escaping the directory.
+func (s *LocalStorage) path(name string) (string, error) {
+	if ContentType(name) == "" {
+		return "", ErrFileNotFound
+	}
+	return filepath.Join(s.Dir, name), nil
+}
@@ -0,0 +1,61 @@
+package covers
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// VP8X feature flags for the metadata chunks.
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// stripWebP removes the EXIF and XMP chunks from a WebP file and clears the
+// matching flags in the extended header. The image data is left untouched.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalidImage
+	}
+
+	var out bytes.Buffer
+	out.Write(data[0:12])
+
+	hasImage := false
+	rest := data[12:]
+	for len(rest) > 0 {
+		if len(rest) < 8 {
+			return nil, ErrInvalidImage
+		}
+		fourCC := string(rest[0:4])
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		// chunks are padded to an even length
+		padded := size + size&1
+		if padded > len(rest)-8 {
+			return nil, ErrInvalidImage
+		}
+		chunk := rest[:8+padded]
+		rest = rest[8+padded:]
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			if size < 1 {
+				return nil, ErrInvalidImage
+			}
+			chunk = bytes.Clone(chunk)
+			chunk[8] &^= webpFlagEXIF | webpFlagXMP
+		case "VP8 ", "VP8L", "ANIM":
+			hasImage = true
+		}
+		out.Write(chunk)
+	}
+	if !hasImage {
+		return nil, ErrInvalidImage
+	}
+
+	clean := out.Bytes()
+	binary.LittleEndian.PutUint32(clean[4:8], uint32(len(clean)-8))
+	return clean, nil
+}
@@ -0,0 +1,303 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// contributor roles
+const (
+	RoleAuthor      = "author"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+	RoleEditor      = "editor"
+)
+
+var ContributorRoles = []string{RoleAuthor, RoleTranslator, RoleIllustrator, RoleEditor}
+
+// Author is a person credited on one or more books.
+type Author struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	BookCount int       `json:"book_count"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"version"`
+}
+
+// Contributor links an author to a book in a given role. Position orders the
+// contributors that share a role.
+type Contributor struct {
+	AuthorID int64  `json:"author_id"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Position int    `json:"position"`
+}
+
+// AuthorBook is a book as listed on an author's page, with the role the
+// author had on it.
+type AuthorBook struct {
+	Role string `json:"role"`
+	*Book
+}
+
+// AuthorModel handles the database interactions for authors.
+type AuthorModel struct {
+	DB *sql.DB
+}
+
+// NormalizeAuthorName reduces a name to lower case letters and digits. It
+// matches the normalized_name column of the authors table.
+func NormalizeAuthorName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func ValidateContributors(v *validator.Validator, contributors []Contributor) {
+	for _, c := range contributors {
+		v.Check(strings.TrimSpace(c.Name) != "", "contributors", "every contributor must have a name")
+		v.Check(len(c.Name) <= 255, "contributors", "contributor names must not be more than 255 characters long")
+		v.Check(validator.In(c.Role, ContributorRoles...), "contributors", "role must be one of 'author', 'translator', 'illustrator' or 'editor'")
+	}
+}
+
+// SetContributors replaces the book's contributors. A missing role means
+// author. When any contributor is an author the authors list is rebuilt from
+// them, in order, otherwise the existing authors are kept.
+func (b *Book) SetContributors(contributors []Contributor) {
+	authors := []string{}
+	positions := make(map[string]int)
+	for i := range contributors {
+		if contributors[i].Role == "" {
+			contributors[i].Role = RoleAuthor
+		}
+		contributors[i].Position = positions[contributors[i].Role]
+		positions[contributors[i].Role]++
+		if contributors[i].Role == RoleAuthor {
+			authors = append(authors, contributors[i].Name)
+		}
+	}
+
+	if len(authors) > 0 {
+		b.Authors = authors
+	}
+	b.Contributors = contributors
+}
+
+// credits returns every contributor to save for the book. The authors list
+// is the source for the author role so that clients which only send
+// "authors" keep working.
+func (b *Book) credits() []Contributor {
+	credits := []Contributor{}
+	for i, name := range b.Authors {
+		credits = append(credits, Contributor{Name: name, Role: RoleAuthor, Position: i})
+	}
+	for _, c := range b.Contributors {
+		if c.Role != RoleAuthor {
+			credits = append(credits, c)
+		}
+	}
+	return credits
+}
+
+// saveContributors replaces the contributor rows of a book inside tx,
+// creating authors that do not exist yet.
+func saveContributors(ctx context.Context, tx *sql.Tx, book *Book) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM book_contributors WHERE book_id = $1`, book.ID)
+	if err != nil {
+		return err
+	}
+
+	credits := book.credits()
+	for i := range credits {
+		name := strings.TrimSpace(credits[i].Name)
+		normalized := NormalizeAuthorName(name)
+		if normalized == "" {
+			continue
+		}
+
+		// the no-op update lets RETURNING hand back the id of an existing author
+		query := `
+			INSERT INTO authors (name, normalized_name)
+			VALUES ($1, $2)
+			ON CONFLICT (normalized_name) DO UPDATE SET normalized_name = EXCLUDED.normalized_name
+			RETURNING id, name`
+		err := tx.QueryRowContext(ctx, query, name, normalized).Scan(&credits[i].AuthorID, &credits[i].Name)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO book_contributors (book_id, author_id, role, position)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT DO NOTHING`
+		_, err = tx.ExecContext(ctx, query, book.ID, credits[i].AuthorID, credits[i].Role, credits[i].Position)
+		if err != nil {
+			return err
+		}
+	}
+
+	book.Contributors = credits
+	return nil
+}
+
+// getContributors returns the contributors of a book, authors first.
+func getContributors(db queryer, bookID int) ([]Contributor, error) {
+	query := `
+		SELECT a.id, a.name, c.role, c.position
+		FROM book_contributors c
+		INNER JOIN authors a ON a.id = c.author_id
+		WHERE c.book_id = $1
+		ORDER BY c.role <> 'author', c.role, c.position`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contributors := []Contributor{}
+	for rows.Next() {
+		var c Contributor
+		err := rows.Scan(&c.AuthorID, &c.Name, &c.Role, &c.Position)
+		if err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, c)
+	}
+
+	return contributors, rows.Err()
+}
+
+// Get retrieves a single author by ID.
+func (m *AuthorModel) Get(id int64) (*Author, error) {
+	query := `
+		SELECT a.id, a.name, a.created_at, a.version,
+			(SELECT COUNT(DISTINCT c.book_id) FROM book_contributors c
+				INNER JOIN books ON books.id = c.book_id
+				WHERE c.author_id = a.id AND books.deleted_at IS NULL)
+		FROM authors a
+		WHERE a.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var author Author
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&author.ID, &author.Name, &author.CreatedAt, &author.Version, &author.BookCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &author, nil
+}
+
+// GetAll retrieves the authors whose name contains name.
+func (m *AuthorModel) GetAll(name string, filters Filters) ([]*Author, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), a.id, a.name, a.created_at, a.version,
+			(SELECT COUNT(DISTINCT c.book_id) FROM book_contributors c
+				INNER JOIN books ON books.id = c.book_id
+				WHERE c.author_id = a.id AND books.deleted_at IS NULL)
+		FROM authors a
+		WHERE ($1 = '' OR a.name ILIKE '%%' || $1 || '%%')
+		ORDER BY %s %s, a.id ASC
+		LIMIT $2 OFFSET $3`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	authors := []*Author{}
+
+	for rows.Next() {
+		var author Author
+		err := rows.Scan(
+			&totalRecords,
+			&author.ID,
+			&author.Name,
+			&author.CreatedAt,
+			&author.Version,
+			&author.BookCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		authors = append(authors, &author)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return authors, metadata, nil
+}
+
+// GetBooks retrieves the books an author contributed to, with their role.
+func (m *AuthorModel) GetBooks(authorID int64, filters Filters) ([]*AuthorBook, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), c.role, %s
+		FROM book_contributors c
+		INNER JOIN books ON books.id = c.book_id
+		WHERE c.author_id = $1 AND books.deleted_at IS NULL
+		ORDER BY %s %s, books.id ASC
+		LIMIT $2 OFFSET $3`, bookColumns, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, authorID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	books := []*AuthorBook{}
+
+	for rows.Next() {
+		book := AuthorBook{Book: &Book{}}
+		err := rows.Scan(append([]any{&totalRecords, &book.Role}, book.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		book.setDerivedFields()
+		books = append(books, &book)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return books, metadata, nil
+}
@@ -5,10 +5,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-
-	//"strings"
 	"time"
 
+	"github.com/RayMC17/bookclub-api/internal/covers"
+	"github.com/RayMC17/bookclub-api/internal/isbn"
 	"github.com/RayMC17/bookclub-api/internal/validator"
 	"github.com/lib/pq" // Update the path according to your module path
 )
@@ -17,14 +17,157 @@ var ErrRecordNotFound = errors.New("record not found")
 
 // Book model definition
 type Book struct {
-	ID              int       `json:"id"`
-	Title           string    `json:"title"`
-	Authors         []string  `json:"authors"`
-	ISBN            string    `json:"isbn"`
-	PublicationDate time.Time `json:"publication_date"`
-	Genre           string    `json:"genre"`
-	Description     string    `json:"description"`
-	AverageRating   float64   `json:"average_rating"`
+	ID              int           `json:"id"`
+	WorkID          int64         `json:"work_id"`
+	Title           string        `json:"title"`
+	Authors         []string      `json:"authors"`
+	ISBN            string        `json:"isbn"`
+	ISBNDisplay     string        `json:"isbn_display"`
+	PublicationDate time.Time     `json:"publication_date"`
+	Genre           string        `json:"genre"`
+	Description     string        `json:"description"`
+	PublisherID     *int64        `json:"publisher_id"`
+	Publisher       string        `json:"publisher"`
+	Language        string        `json:"language"`
+	Pages           *int          `json:"pages"`
+	Format          string        `json:"format"`
+	DurationMinutes *int          `json:"duration_minutes,omitempty"`
+	OriginalYear    *int          `json:"original_year"`
+	AverageRating   float64       `json:"average_rating"`
+	ReviewCount     int           `json:"review_count"`
+	RatingHistogram map[int]int   `json:"rating_histogram,omitempty"`
+	Contributors    []Contributor `json:"contributors,omitempty"`
+	Genres          []BookGenre   `json:"genres,omitempty"`
+	Series          []BookSeries  `json:"series,omitempty"`
+	CoverKey        string        `json:"-"`
+	Cover           *covers.URLs  `json:"cover,omitempty"`
+	Rank            float64       `json:"rank,omitempty"`
+	Highlight       string        `json:"highlight,omitempty"`
+	Version         int           `json:"version"`
+}
+
+// edition formats
+const (
+	FormatHardcover = "hardcover"
+	FormatPaperback = "paperback"
+	FormatEbook     = "ebook"
+	FormatAudiobook = "audiobook"
+)
+
+var BookFormats = []string{FormatHardcover, FormatPaperback, FormatEbook, FormatAudiobook}
+
+// BookSearch holds the criteria accepted by GetAllFilters. Query is matched
+// against the full-text search vector and drives the ranking, while Title,
+// Author and Genre narrow the result set further.
+type BookSearch struct {
+	Query  string
+	Title  string
+	Author string
+	Genre  string
+	ISBN   string
+	// GenreSlug matches books in the genre or any of its descendants
+	GenreSlug string
+	// AuthorID, Decade and Rating take the values returned in BookFacets
+	AuthorID int64
+	Decade   int
+	Rating   *int
+	// SeriesID matches the books of a series
+	SeriesID int64
+	// Tag matches books any member has put the tag on
+	Tag string
+	// Language, PublisherID and Format match exactly, the ranges are
+	// inclusive and skip books where the value is unknown
+	Language        string
+	PublisherID     int64
+	Format          string
+	PagesGTE        *int
+	PagesLTE        *int
+	OriginalYearGTE *int
+	OriginalYearLTE *int
+}
+
+// bookSearchWhere is the WHERE clause shared by every query that takes a
+// BookSearch. Its placeholders line up with the values returned by args.
+const bookSearchWhere = `
+	WHERE ($1 = '' OR search_vector @@ websearch_to_tsquery('english', $1))
+		AND ($2 = '' OR title ILIKE '%' || $2 || '%')
+		AND ($3 = '' OR genre ILIKE '%' || $3 || '%')
+		AND ($4 = '' OR EXISTS (
+			SELECT 1 FROM unnest(authors) author WHERE author ILIKE '%' || $4 || '%'
+		))
+		AND ($5 = '' OR isbn = $5)
+		AND ($6 = '' OR books.id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM genres WHERE slug = $6
+				UNION ALL
+				SELECT g.id FROM genres g INNER JOIN tree ON g.parent_id = tree.id
+			)
+			SELECT bg.book_id FROM book_genres bg INNER JOIN tree ON tree.id = bg.genre_id
+		))
+		AND ($7 = 0 OR books.id IN (
+			SELECT book_id FROM book_contributors WHERE author_id = $7 AND role = 'author'
+		))
+		AND ($8 = 0 OR (books.publication_date >= make_date($8, 1, 1)
+			AND books.publication_date < make_date($8 + 10, 1, 1)))
+		AND ($9::int IS NULL OR floor(coalesce(books.average_rating, 0)) = $9)
+		AND ($10 = 0 OR books.id IN (SELECT book_id FROM book_series WHERE series_id = $10))
+		AND ($11 = '' OR books.id IN (SELECT book_id FROM book_tags WHERE tag = $11))
+		AND ($12 = '' OR books.language = $12)
+		AND ($13 = 0 OR books.publisher_id = $13)
+		AND ($14 = '' OR books.format = $14)
+		AND ($15::int IS NULL OR books.pages >= $15)
+		AND ($16::int IS NULL OR books.pages <= $16)
+		AND ($17::int IS NULL OR books.original_year >= $17)
+		AND ($18::int IS NULL OR books.original_year <= $18)
+		AND books.deleted_at IS NULL`
+
+func (s BookSearch) args() []any {
+	return []any{s.Query, s.Title, s.Genre, s.Author, s.ISBN, s.GenreSlug, s.AuthorID, s.Decade, s.Rating, s.SeriesID, s.Tag,
+		s.Language, s.PublisherID, s.Format, s.PagesGTE, s.PagesLTE, s.OriginalYearGTE, s.OriginalYearLTE}
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx, so the helpers loading
+// the parts of a book also work inside a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// bookColumns is the column list every book query selects, in the order
+// expected by scanTargets.
+const bookColumns = `books.id, books.work_id, books.title, books.authors, books.isbn, books.publication_date,
+	books.genre, books.description, books.publisher_id,
+	coalesce((SELECT name FROM publishers WHERE publishers.id = books.publisher_id), ''),
+	books.language, books.pages, books.format, books.duration_minutes, books.original_year, books.average_rating, books.review_count, books.cover_key, books.version`
+
+// scanTargets returns the destinations for the columns in bookColumns.
+func (b *Book) scanTargets() []any {
+	return []any{
+		&b.ID,
+		&b.WorkID,
+		&b.Title,
+		pq.Array(&b.Authors),
+		&b.ISBN,
+		&b.PublicationDate,
+		&b.Genre,
+		&b.Description,
+		&b.PublisherID,
+		&b.Publisher,
+		&b.Language,
+		&b.Pages,
+		&b.Format,
+		&b.DurationMinutes,
+		&b.OriginalYear,
+		&b.AverageRating,
+		&b.ReviewCount,
+		&b.CoverKey,
+		&b.Version,
+	}
+}
+
+// setDerivedFields fills in the fields computed from the stored columns.
+func (b *Book) setDerivedFields() {
+	b.ISBNDisplay = isbn.Hyphenate(b.ISBN)
+	b.Cover = covers.URLsFor(b.CoverKey)
 }
 
 // // ReadingList model definition
@@ -45,112 +188,359 @@ func ValidateBook(v *validator.Validator, book *Book) {
 	v.Check(book.Title != "", "title", "must be provided")
 	v.Check(len(book.Title) <= 255, "title", "must not be more than 255 characters long")
 	v.Check(len(book.Authors) > 0, "authors", "must have at least one author")
+	ValidateContributors(v, book.Contributors)
 	v.Check(book.ISBN != "", "isbn", "must be provided")
-	v.Check(len(book.ISBN) == 13, "isbn", "must be exactly 13 characters long")
+	if book.ISBN != "" {
+		book.ISBN = ValidateISBN(v, book.ISBN)
+	}
 	v.Check(book.PublicationDate.Before(time.Now()), "publication_date", "must be in the past")
 	v.Check(book.Genre != "", "genre", "must be provided")
 	v.Check(len(book.Genre) <= 50, "genre", "must not be more than 50 characters long")
 	v.Check(len(book.Description) <= 1000, "description", "must not be more than 1000 characters long")
-	v.Check(book.AverageRating >= 0 && book.AverageRating <= 5, "average_rating", "must be between 0 and 5")
+	v.Check(len(book.Publisher) <= 255, "publisher", "must not be more than 255 characters long")
+	v.Check(book.Language == "" || IsLanguageCode(book.Language), "language", "must be an ISO 639-1 language code such as en")
+	v.Check(book.Pages == nil || *book.Pages > 0, "pages", "must be a positive integer")
+	v.Check(book.Format == "" || validator.In(book.Format, BookFormats...), "format", "must be hardcover, paperback, ebook or audiobook")
+	if book.DurationMinutes != nil {
+		v.Check(book.Format == FormatAudiobook, "duration_minutes", "is only for audiobooks")
+		v.Check(*book.DurationMinutes > 0, "duration_minutes", "must be a positive integer")
+	}
+	if book.OriginalYear != nil {
+		v.Check(*book.OriginalYear != 0, "original_year", "must not be zero")
+		v.Check(*book.OriginalYear <= time.Now().Year(), "original_year", "must not be in the future")
+		v.Check(book.PublicationDate.IsZero() || *book.OriginalYear <= book.PublicationDate.Year(), "original_year", "must not be after the publication date")
+	}
+}
+
+// ValidateBookSearch checks the search filters that take facet values.
+func ValidateBookSearch(v *validator.Validator, search BookSearch) {
+	v.Check(search.AuthorID >= 0, "author_id", "must be a positive integer")
+	v.Check(search.SeriesID >= 0, "series_id", "must be a positive integer")
+	v.Check(search.PublisherID >= 0, "publisher_id", "must be a positive integer")
+	v.Check(search.Language == "" || IsLanguageCode(search.Language), "language", "must be an ISO 639-1 language code such as en")
+	v.Check(search.Format == "" || validator.In(search.Format, BookFormats...), "book_format", "must be hardcover, paperback, ebook or audiobook")
+	v.Check(search.PagesGTE == nil || search.PagesLTE == nil || *search.PagesGTE <= *search.PagesLTE, "pages_lte", "must not be less than pages_gte")
+	v.Check(search.OriginalYearGTE == nil || search.OriginalYearLTE == nil || *search.OriginalYearGTE <= *search.OriginalYearLTE, "original_year_lte", "must not be less than original_year_gte")
+	v.Check(search.Decade == 0 || (search.Decade%10 == 0 && search.Decade >= 1000 && search.Decade <= 2100), "decade", "must be the first year of a decade, e.g. 1990")
+	if search.Rating != nil {
+		v.Check(*search.Rating >= 0 && *search.Rating <= 5, "rating", "must be between 0 and 5")
+	}
+}
+
+// ValidateISBN checks an ISBN-10 or ISBN-13 and returns it in its canonical
+// ISBN-13 form. The value is returned unchanged when it is not valid.
+func ValidateISBN(v *validator.Validator, value string) string {
+	canonical, err := isbn.Parse(value)
+	switch {
+	case errors.Is(err, isbn.ErrInvalidCharacter):
+		v.AddError("isbn", "must only contain digits, hyphens and spaces")
+	case errors.Is(err, isbn.ErrInvalidLength):
+		v.AddError("isbn", "must contain exactly 10 or 13 digits")
+	case errors.Is(err, isbn.ErrInvalidChecksum):
+		v.AddError("isbn", "has an invalid check digit")
+	default:
+		return canonical
+	}
+	return value
 }
 
 // BookModel methods (Insert, Get, Update, Delete, GetAll) as defined in your code
-// Insert a new book
-func (m *BookModel) Insert(book *Book) error {
-	//authors := strings.Join(book.Authors, ",")
+// Insert a new book along with its contributors and genres. A book without a
+// WorkID becomes the first edition of a new work. The new book is recorded
+// as its first revision, made by editorID.
+func (m *BookModel) Insert(book *Book, editorID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertBook(ctx, tx, book, editorID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertBook does the work of Insert inside tx.
+func insertBook(ctx context.Context, tx *sql.Tx, book *Book, editorID int) error {
+	query := `
+        INSERT INTO books (work_id, title, authors, isbn, publication_date, genre, description,
+            publisher_id, language, pages, format, duration_minutes, original_year)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        RETURNING id, version`
+
+	if book.WorkID == 0 {
+		err := tx.QueryRowContext(ctx, `INSERT INTO works (title) VALUES ($1) RETURNING id`, book.Title).Scan(&book.WorkID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := savePublisher(ctx, tx, book)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{book.WorkID, book.Title, pq.Array(book.Authors), book.ISBN, book.PublicationDate, book.Genre, book.Description,
+		book.PublisherID, book.Language, book.Pages, book.Format, book.DurationMinutes, book.OriginalYear}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&book.ID, &book.Version)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrUnknownWork
+		}
+		return err
+	}
+
+	err = saveContributors(ctx, tx, book)
+	if err != nil {
+		return err
+	}
+
+	err = saveBookGenres(ctx, tx, book)
+	if err != nil {
+		return err
+	}
+
+	err = saveBookTerms(ctx, tx, book.ID)
+	if err != nil {
+		return err
+	}
+
+	return recordRevision(ctx, tx, book, editorID, nil)
+}
+
+// Get a single book by ID, along with its rating histogram
+func (m *BookModel) Get(id int) (*Book, error) {
 	query := `
-        INSERT INTO books (title, authors, isbn, publication_date, genre, description, average_rating)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-        RETURNING id`
-	args := []interface{}{book.Title, pq.Array(book.Authors), book.ISBN, book.PublicationDate, book.Genre, book.Description, book.AverageRating}
+        SELECT ` + bookColumns + `, books.rating_histogram
+        FROM books
+        WHERE id = $1 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&book.ID)
+	var book Book
+	var histogram []int64
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(append(book.scanTargets(), pq.Array(&histogram))...)
+	if err == sql.ErrNoRows {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	book.setDerivedFields()
+	book.RatingHistogram = histogramFrom(histogram)
+
+	book.Contributors, err = getContributors(m.DB, book.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	book.Genres, err = getBookGenres(m.DB, book.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	book.Series, err = getBookSeries(m.DB, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
 }
 
-// Get a single book by ID
-func (m *BookModel) Get(id int) (*Book, error) {
+// GetByISBN retrieves a single book by its canonical ISBN-13
+func (m *BookModel) GetByISBN(isbn13 string) (*Book, error) {
 	query := `
-        SELECT id, title, authors, isbn, publication_date, genre, description, average_rating
+        SELECT ` + bookColumns + `
         FROM books
-        WHERE id = $1`
+        WHERE isbn = $1 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var book Book
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&book.ID, &book.Title, pq.Array(&book.Authors), &book.ISBN,
-		&book.PublicationDate, &book.Genre, &book.Description, &book.AverageRating,
-	)
+	err := m.DB.QueryRowContext(ctx, query, isbn13).Scan(book.scanTargets()...)
 	if err == sql.ErrNoRows {
 		return nil, ErrRecordNotFound
 	}
+	book.setDerivedFields()
 	return &book, err
 }
 
-// Update a book
-func (m *BookModel) Update(book *Book) error {
+// Update a book and replace its contributors and genres. The edit is
+// recorded as a revision made by editorID. It returns ErrEditConfilct when
+// the book has changed since it was read.
+func (m *BookModel) Update(book *Book, editorID int) error {
+	return m.update(book, editorID, nil)
+}
+
+// Revert saves a book that has been reset to an earlier revision. It is
+// recorded as a new revision pointing back at the one restored.
+func (m *BookModel) Revert(book *Book, editorID, revision int) error {
+	return m.update(book, editorID, &revision)
+}
+
+func (m *BookModel) update(book *Book, editorID int, revertedFrom *int) error {
 	query := `
         UPDATE books
-        SET title = $1, authors = $2, isbn = $3, publication_date = $4, genre = $5, description = $6, average_rating = $7
-        WHERE id = $8`
-	args := []interface{}{book.Title, pq.Array(book.Authors), book.ISBN, book.PublicationDate, book.Genre, book.Description, book.AverageRating, book.ID}
+        SET title = $1, authors = $2, isbn = $3, publication_date = $4, genre = $5, description = $6,
+            publisher_id = $7, language = $8, pages = $9, format = $10, duration_minutes = $11, original_year = $12,
+            version = version + 1
+        WHERE id = $13 AND version = $14 AND deleted_at IS NULL
+        RETURNING version`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...)
-	return err.Err()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = savePublisher(ctx, tx, book)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{book.Title, pq.Array(book.Authors), book.ISBN, book.PublicationDate, book.Genre, book.Description,
+		book.PublisherID, book.Language, book.Pages, book.Format, book.DurationMinutes, book.OriginalYear, book.ID, book.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&book.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConfilct
+		default:
+			return err
+		}
+	}
+
+	err = saveContributors(ctx, tx, book)
+	if err != nil {
+		return err
+	}
+
+	err = saveBookGenres(ctx, tx, book)
+	if err != nil {
+		return err
+	}
+
+	err = saveBookTerms(ctx, tx, book.ID)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, book, editorID, revertedFrom)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Delete a book by ID
-func (m *BookModel) Delete(id int) error {
-	query := `DELETE FROM books WHERE id = $1`
+// Delete moves a book to the trash. Its reviews and list entries are kept
+// so that restoring the book brings them back.
+func (m *BookModel) Delete(id int, deletedBy int) error {
+	query := `
+        UPDATE books
+        SET deleted_at = NOW(), deleted_by = $2
+        WHERE id = $1 AND deleted_at IS NULL`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id)
-	return err.Err()
+	result, err := m.DB.ExecContext(ctx, query, id, deletedBy)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// bookRank scores a book against the search query in $1. It is rounded so
+// that the value handed out in a cursor compares equal to the stored one.
+const bookRank = `round((CASE WHEN $1 = '' THEN 0
+		ELSE ts_rank(books.search_vector, websearch_to_tsquery('english', $1))
+	END)::numeric, 6)`
+
+// bookSortKeys maps the sort columns accepted by the book endpoints to the
+// expressions the queries order by and keyset pagination compares against.
+var bookSortKeys = map[string]string{
+	"id":     "books.id",
+	"title":  "books.title",
+	"genre":  "coalesce(books.genre, '')",
+	"author": "coalesce(books.authors[1], '')",
+	"rank":   bookRank,
 }
 
-// GetAll retrieves all books with optional filters and pagination.
-func (m *BookModel) GetAll(filters Filters) ([]*Book, Metadata, error) {
+// sortKey returns the value of the book's sort column, for use in a cursor.
+func (b *Book) sortKey(column string) any {
+	switch column {
+	case "title":
+		return b.Title
+	case "genre":
+		return b.Genre
+	case "author":
+		if len(b.Authors) == 0 {
+			return ""
+		}
+		return b.Authors[0]
+	case "rank":
+		return b.Rank
+	default:
+		return b.ID
+	}
+}
+
+// GetAll retrieves all books with optional filters and pagination. An empty
+// isbn13 matches every book.
+func (m *BookModel) GetAll(isbn13 string, filters Filters) ([]*Book, Metadata, error) {
+	ks, err := newKeyset(filters, bookSortKeys[filters.SortColumn()], "books.id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	cond, tail, pageArgs, err := ks.clause(2)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	where := `WHERE ($1 = '' OR isbn = $1) AND deleted_at IS NULL`
 	query := fmt.Sprintf(`
-        SELECT COUNT(*) OVER(), id, title, authors, isbn, publication_date, genre, description, average_rating
+        SELECT %s
         FROM books
-        ORDER BY %s %s, id ASC
-        LIMIT $1 OFFSET $2`, filters.SortColumn(), filters.SortDirection())
+        %s AND %s
+        %s`, bookColumns, where, cond, tail)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, filters.Limit(), filters.Offset())
+	rows, err := m.DB.QueryContext(ctx, query, append([]any{isbn13}, pageArgs...)...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
-	totalRecords := 0
 	books := []*Book{}
 
 	for rows.Next() {
 		var book Book
-		err := rows.Scan(
-			&totalRecords,
-			&book.ID,
-			&book.Title,
-			pq.Array(&book.Authors),
-			&book.ISBN,
-			&book.PublicationDate,
-			&book.Genre,
-			&book.Description,
-			&book.AverageRating,
-		)
+		err := rows.Scan(book.scanTargets()...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
+		book.setDerivedFields()
 		books = append(books, &book)
 	}
 
@@ -159,52 +549,66 @@ func (m *BookModel) GetAll(filters Filters) ([]*Book, Metadata, error) {
 		return nil, Metadata{}, err
 	}
 
-	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	totalRecords := 0
+	if filters.WithTotal {
+		err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM books `+where, isbn13).Scan(&totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	books, more := keysetPage(ks, books)
+	metadata := ks.metadata(totalRecords, more, len(books), func(i int) (any, int64) {
+		return books[i].sortKey(filters.SortColumn()), int64(books[i].ID)
+	})
 	return books, metadata, nil
 }
 
-// GetAll retrieves all books with optional filters and pagination.
-func (m *BookModel) GetAllFilters(title string, author string, genre string, filters Filters) ([]*Book, Metadata, error) {
+// GetAllFilters searches the books using the full-text search vector and ranks
+// the results with ts_rank. The remaining criteria are AND-ed together.
+func (m *BookModel) GetAllFilters(search BookSearch, filters Filters) ([]*Book, Metadata, error) {
+	args := search.args()
+
+	ks, err := newKeyset(filters, bookSortKeys[filters.SortColumn()], "books.id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	cond, tail, pageArgs, err := ks.clause(len(args) + 1)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
 	query := fmt.Sprintf(`
-	SELECT COUNT(*) OVER(), id, title, authors, isbn, publication_date, genre, description, average_rating
+	SELECT %s,
+		%s AS rank,
+		CASE WHEN $1 = '' THEN ''
+			ELSE ts_headline('english', title || ' ' || coalesce(description, ''), websearch_to_tsquery('english', $1),
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10')
+		END AS highlight
 	FROM books
-	WHERE (title ILIKE '%%' || $1 || '%%' OR $1 = '')
-  		OR (genre ILIKE '%%' || $2 || '%%' OR $2 = '')
-  		OR ($3 = '' OR EXISTS (
-        	SELECT 1 FROM unnest(authors) author WHERE author ILIKE '%%' || $3 || '%%'
-      	))
-	ORDER BY %s %s, id ASC
-	LIMIT $4 OFFSET $5
-`, filters.SortColumn(), filters.SortDirection())
+	%s
+	AND %s
+	%s
+`, bookColumns, bookRank, bookSearchWhere, cond, tail)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, title, genre, author, filters.Limit(), filters.Offset())
+	rows, err := m.DB.QueryContext(ctx, query, append(args, pageArgs...)...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
-	totalRecords := 0
 	books := []*Book{}
 
 	for rows.Next() {
 		var book Book
-		err := rows.Scan(
-			&totalRecords,
-			&book.ID,
-			&book.Title,
-			pq.Array(&book.Authors),
-			&book.ISBN,
-			&book.PublicationDate,
-			&book.Genre,
-			&book.Description,
-			&book.AverageRating,
-		)
+		err := rows.Scan(append(book.scanTargets(), &book.Rank, &book.Highlight)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
+		book.setDerivedFields()
 		books = append(books, &book)
 	}
 
@@ -213,15 +617,105 @@ func (m *BookModel) GetAllFilters(title string, author string, genre string, fil
 		return nil, Metadata{}, err
 	}
 
-	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	totalRecords := 0
+	if filters.WithTotal {
+		err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM books `+bookSearchWhere, search.args()...).Scan(&totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	books, more := keysetPage(ks, books)
+	metadata := ks.metadata(totalRecords, more, len(books), func(i int) (any, int64) {
+		return books[i].sortKey(filters.SortColumn()), int64(books[i].ID)
+	})
 	return books, metadata, nil
 }
 
+// Export streams every book matching the search to fn, one row at a time, so
+// the whole catalog never has to be held in memory. Results come back in
+// relevance order when there is a query and by id otherwise. The query is
+// cancelled along with ctx, e.g. when the client goes away.
+func (m *BookModel) Export(ctx context.Context, search BookSearch, fn func(*Book) error) error {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM books
+	%s
+	ORDER BY CASE WHEN $1 = '' THEN 0
+		ELSE ts_rank(search_vector, websearch_to_tsquery('english', $1))
+	END DESC, id ASC
+`, bookColumns, bookSearchWhere)
+
+	// a full export takes far longer than a single page
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, search.args()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book Book
+		err := rows.Scan(book.scanTargets()...)
+		if err != nil {
+			return err
+		}
+		book.setDerivedFields()
+
+		err = fn(&book)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// SetCover stores the key of a book's new cover and returns the key of the
+// cover it replaced, which is empty when there was none.
+func (m *BookModel) SetCover(id int, key string) (string, error) {
+	query := `
+		UPDATE books b SET cover_key = $1, version = b.version + 1
+		FROM (SELECT id, cover_key FROM books WHERE id = $2 AND deleted_at IS NULL FOR UPDATE) old
+		WHERE b.id = old.id
+		RETURNING old.cover_key`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var previous string
+	err := m.DB.QueryRowContext(ctx, query, key, id).Scan(&previous)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+	return previous, nil
+}
+
+// CoverInUse reports whether any book still uses the cover key. Covers are
+// named after their content, so books with the same image share the files.
+func (m *BookModel) CoverInUse(key string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM books WHERE cover_key = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var inUse bool
+	err := m.DB.QueryRowContext(ctx, query, key).Scan(&inUse)
+	return inUse, err
+}
+
 func (m *BookModel) BookExists(id int) error {
 	query := `
         SELECT id
         FROM books
-        WHERE id = $1`
+        WHERE id = $1 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -235,3 +729,38 @@ func (m *BookModel) BookExists(id int) error {
 	}
 	return nil
 }
+
+// GetByIDs loads the books with the given ids in one query, keyed by id.
+// Missing and deleted books are left out.
+func (m *BookModel) GetByIDs(ids []int64) (map[int64]*Book, error) {
+	books := make(map[int64]*Book, len(ids))
+	if len(ids) == 0 {
+		return books, nil
+	}
+
+	query := `
+        SELECT ` + bookColumns + `
+        FROM books
+        WHERE id = ANY($1) AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book Book
+		err := rows.Scan(book.scanTargets()...)
+		if err != nil {
+			return nil, err
+		}
+		book.setDerivedFields()
+		books[int64(book.ID)] = &book
+	}
+
+	return books, rows.Err()
+}
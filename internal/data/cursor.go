@@ -0,0 +1,160 @@
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Keyset pagination. A cursor holds the sort key and id of the row a page
+// continues from, so a deep page costs the same as the first one and rows
+// added meanwhile do not shift the pages around.
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type cursor struct {
+	Sort string          `json:"s"`
+	Key  json.RawMessage `json:"k"`
+	ID   int64           `json:"i"`
+	// Before pages backwards from the row
+	Before bool `json:"b,omitempty"`
+}
+
+func encodeCursor(sort string, key any, id int64, before bool) string {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return ""
+	}
+	js, err := json.Marshal(cursor{Sort: sort, Key: k, ID: id, Before: before})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	js, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	err = json.Unmarshal(js, &c)
+	if err != nil || len(c.Key) == 0 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// keyset plans one page of a query ordered by sortKey and then idColumn.
+type keyset struct {
+	filters  Filters
+	sortKey  string
+	idColumn string
+	cursor   *cursor
+}
+
+func newKeyset(filters Filters, sortKey, idColumn string) (*keyset, error) {
+	k := &keyset{filters: filters, sortKey: sortKey, idColumn: idColumn}
+	if filters.Cursor == "" {
+		return k, nil
+	}
+
+	c, err := decodeCursor(filters.Cursor)
+	if err != nil || c.Sort != filters.Sort {
+		return nil, ErrInvalidCursor
+	}
+	k.cursor = &c
+	return k, nil
+}
+
+func (k *keyset) backward() bool {
+	return k.cursor != nil && k.cursor.Before
+}
+
+// clause returns the condition selecting the rows after the cursor, which
+// is TRUE without one, and the ORDER BY, LIMIT and OFFSET for the page.
+// Their placeholders start at argN. One row more than the page size is
+// fetched to tell whether there is a next page.
+func (k *keyset) clause(argN int) (string, string, []any, error) {
+	desc := k.filters.SortDirection() == "DESC"
+	if k.backward() {
+		desc = !desc
+	}
+	direction, op := "ASC", ">"
+	if desc {
+		direction, op = "DESC", "<"
+	}
+
+	cond := "TRUE"
+	args := []any{}
+	offset := k.filters.Offset()
+	if k.cursor != nil {
+		dec := json.NewDecoder(bytes.NewReader(k.cursor.Key))
+		// numbers are passed on as text so ids and ranks keep every digit
+		dec.UseNumber()
+		var key any
+		err := dec.Decode(&key)
+		if err != nil {
+			return "", "", nil, ErrInvalidCursor
+		}
+
+		cond = fmt.Sprintf("(%s, %s) %s ($%d, $%d)", k.sortKey, k.idColumn, op, argN, argN+1)
+		args = append(args, key, k.cursor.ID)
+		argN += 2
+		offset = 0
+	}
+
+	tail := fmt.Sprintf("ORDER BY %s %s, %s %s LIMIT $%d OFFSET $%d",
+		k.sortKey, direction, k.idColumn, direction, argN, argN+1)
+	args = append(args, k.filters.Limit()+1, offset)
+	return cond, tail, args, nil
+}
+
+// keysetPage trims the extra row fetched by clause and puts the rows back in
+// page order. It reports whether there are more rows in the direction paged.
+func keysetPage[T any](k *keyset, rows []T) ([]T, bool) {
+	more := len(rows) > k.filters.Limit()
+	if more {
+		rows = rows[:k.filters.Limit()]
+	}
+	if k.backward() {
+		slices.Reverse(rows)
+	}
+	return rows, more
+}
+
+// metadata builds the page metadata. count is the number of rows on the page
+// and key returns the sort key and id of row i. total is only used when the
+// filters asked for it.
+func (k *keyset) metadata(total int, more bool, count int, key func(i int) (any, int64)) Metadata {
+	var metadata Metadata
+	if k.filters.WithTotal {
+		metadata = CalculateMetadata(total, k.filters.Page, k.filters.PageSize)
+	}
+	metadata.PageSize = k.filters.PageSize
+	// page numbers mean nothing once paging by cursor
+	metadata.CurrentPage = 0
+	if k.cursor == nil {
+		metadata.CurrentPage = k.filters.Page
+	}
+	if count == 0 {
+		return metadata
+	}
+
+	hasNext, hasPrev := more, k.cursor != nil || k.filters.Page > 1
+	if k.backward() {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		value, id := key(count - 1)
+		metadata.NextCursor = encodeCursor(k.filters.Sort, value, id, false)
+	}
+	if hasPrev {
+		value, id := key(0)
+		metadata.PrevCursor = encodeCursor(k.filters.Sort, value, id, true)
+	}
+	return metadata
+}
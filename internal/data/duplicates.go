@@ -0,0 +1,252 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrMergeSelf = errors.New("a book cannot be merged into itself")
+
+// DuplicatePair is two live books that look like the same book entered
+// twice. The scores are between 0 and 1: TitleScore is the trigram
+// similarity of the normalized titles, AuthorScore the share of authors in
+// common and YearScore 1 for the same publication year and 0.5 for
+// neighbouring ones. Score weighs them 0.6, 0.3 and 0.1.
+type DuplicatePair struct {
+	Book        *Book   `json:"book"`
+	Duplicate   *Book   `json:"duplicate"`
+	Score       float64 `json:"score"`
+	TitleScore  float64 `json:"title_score"`
+	AuthorScore float64 `json:"author_score"`
+	YearScore   float64 `json:"year_score"`
+}
+
+// Duplicates lists the likely duplicate pairs scoring at least minScore,
+// best first. Editions of the same work are not duplicates of each other.
+func (m *BookModel) Duplicates(minScore float64, filters Filters) ([]*DuplicatePair, Metadata, error) {
+	query := `
+		WITH pairs AS (
+			SELECT b1.id AS book_id, b2.id AS duplicate_id,
+				similarity(normalize_title(b1.title), normalize_title(b2.title)) AS title_score,
+				author_overlap(b1.authors, b2.authors) AS author_score,
+				CASE abs(extract(year FROM b1.publication_date) - extract(year FROM b2.publication_date))
+					WHEN 0 THEN 1
+					WHEN 1 THEN 0.5
+					ELSE 0
+				END AS year_score
+			FROM books b1
+			INNER JOIN books b2 ON b2.id > b1.id
+				AND normalize_title(b2.title) % normalize_title(b1.title)
+				AND b2.work_id <> b1.work_id
+				AND b2.deleted_at IS NULL
+			WHERE b1.deleted_at IS NULL
+		), scored AS (
+			SELECT *, 0.6 * title_score + 0.3 * author_score + 0.1 * year_score AS score
+			FROM pairs
+		)
+		SELECT COUNT(*) OVER(), book_id, duplicate_id, title_score, author_score, year_score, score
+		FROM scored
+		WHERE score >= $1
+		ORDER BY score DESC, book_id, duplicate_id
+		LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, minScore, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	pairs := []*DuplicatePair{}
+	ids := []int64{}
+
+	for rows.Next() {
+		var pair DuplicatePair
+		var bookID, duplicateID int64
+		err := rows.Scan(
+			&totalRecords,
+			&bookID,
+			&duplicateID,
+			&pair.TitleScore,
+			&pair.AuthorScore,
+			&pair.YearScore,
+			&pair.Score,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		pair.Book = &Book{ID: int(bookID)}
+		pair.Duplicate = &Book{ID: int(duplicateID)}
+		pairs = append(pairs, &pair)
+		ids = append(ids, bookID, duplicateID)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	books, err := m.GetByIDs(ids)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	for _, pair := range pairs {
+		pair.Book = books[int64(pair.Book.ID)]
+		pair.Duplicate = books[int64(pair.Duplicate.ID)]
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return pairs, metadata, nil
+}
+
+// Merge folds the duplicate into the survivor. Reviews and list entries move
+// over, where both books are on the same list the entry of the survivor is
+// kept, marked completed if either was. Genres, contributors, series and
+// members' tags the survivor lacks are copied, its rating is recomputed and the duplicate is removed
+// with a redirect left in its place. The duplicate's cover key is returned
+// so the caller can clean up the files.
+func (m *BookModel) Merge(survivorID, duplicateID, mergedBy int) (string, error) {
+	if survivorID == duplicateID {
+		return "", ErrMergeSelf
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// lock both books, in id order so concurrent merges cannot deadlock
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM books
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE`, pq.Array([]int{survivorID, duplicateID}))
+	if err != nil {
+		return "", err
+	}
+	found := 0
+	for rows.Next() {
+		found++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if found != 2 {
+		return "", ErrRecordNotFound
+	}
+
+	statements := []string{
+		`UPDATE boo_reviews SET book_id = $1, version = version + 1 WHERE book_id = $2`,
+
+		// the primary key of book_lists allows a book once per list
+		`UPDATE book_lists s SET status = 'completed', version = s.version + 1
+		FROM book_lists d
+		WHERE s.book_id = $1 AND d.book_id = $2 AND d.list_name = s.list_name
+		AND d.status = 'completed' AND s.status <> 'completed'`,
+		`DELETE FROM book_lists d
+		USING book_lists s
+		WHERE d.book_id = $2 AND s.book_id = $1 AND s.list_name = d.list_name`,
+		`UPDATE book_lists SET book_id = $1, version = version + 1 WHERE book_id = $2`,
+
+		`INSERT INTO book_genres (book_id, genre_id)
+		SELECT $1, genre_id FROM book_genres WHERE book_id = $2
+		ON CONFLICT DO NOTHING`,
+		`INSERT INTO book_contributors (book_id, author_id, role, position)
+		SELECT $1, author_id, role, position FROM book_contributors WHERE book_id = $2
+		ON CONFLICT DO NOTHING`,
+		`INSERT INTO book_tags (user_id, book_id, tag)
+		SELECT user_id, $1, tag FROM book_tags WHERE book_id = $2
+		ON CONFLICT DO NOTHING`,
+		// where both books are in a series the survivor keeps its position
+		`INSERT INTO book_series (book_id, series_id, position)
+		SELECT $1, series_id, position FROM book_series WHERE book_id = $2
+		ON CONFLICT DO NOTHING`,
+
+		// older redirects to the duplicate now lead to the survivor
+		`UPDATE book_redirects SET book_id = $1 WHERE book_id = $2`,
+	}
+	for _, statement := range statements {
+		_, err = tx.ExecContext(ctx, statement, survivorID, duplicateID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var workID int64
+	var coverKey string
+	err = tx.QueryRowContext(ctx, `DELETE FROM books WHERE id = $1 RETURNING work_id, cover_key`, duplicateID).Scan(&workID, &coverKey)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO book_redirects (old_id, book_id, merged_by) VALUES ($1, $2, $3)`,
+		duplicateID, survivorID, mergedBy)
+	if err != nil {
+		return "", err
+	}
+
+	err = deleteEmptyWorks(ctx, tx, []int64{workID})
+	if err != nil {
+		return "", err
+	}
+
+	err = refreshRatings(ctx, tx, int64(survivorID))
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE books SET version = version + 1 WHERE id = $1`, survivorID)
+	if err != nil {
+		return "", err
+	}
+
+	// the merge shows up in the survivor's history like any other edit
+	var survivor Book
+	err = tx.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = $1`, survivorID).Scan(survivor.scanTargets()...)
+	if err != nil {
+		return "", err
+	}
+	survivor.Contributors, err = getContributors(tx, survivor.ID)
+	if err != nil {
+		return "", err
+	}
+	survivor.Genres, err = getBookGenres(tx, survivor.ID)
+	if err != nil {
+		return "", err
+	}
+	err = recordRevision(ctx, tx, &survivor, mergedBy, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return coverKey, tx.Commit()
+}
+
+// Redirect returns the book a merged book id now points to.
+func (m *BookModel) Redirect(id int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var bookID int
+	err := m.DB.QueryRowContext(ctx, `SELECT book_id FROM book_redirects WHERE old_id = $1`, id).Scan(&bookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+	return bookID, nil
+}
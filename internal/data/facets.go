@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FacetValue is one entry of a facet. Value can be passed back as the
+// facet's query parameter to narrow the search to these books.
+type FacetValue struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// Facet groups the values of one facet with the query parameter they
+// filter on.
+type Facet struct {
+	Param  string       `json:"param"`
+	Values []FacetValue `json:"values"`
+}
+
+// BookFacets breaks a search down by genre, author, publication decade and
+// whole star rating. The counts cover every matching book, not just a page.
+type BookFacets struct {
+	Genre  Facet `json:"genre"`
+	Author Facet `json:"author"`
+	Decade Facet `json:"decade"`
+	Rating Facet `json:"rating"`
+}
+
+const (
+	genreFacetLimit  = 20
+	authorFacetLimit = 10
+)
+
+// Facets counts the books matching the search for each facet value.
+func (m *BookModel) Facets(search BookSearch) (*BookFacets, error) {
+	facets := BookFacets{
+		Genre:  Facet{Param: "genre_slug"},
+		Author: Facet{Param: "author_id"},
+		Decade: Facet{Param: "decade"},
+		Rating: Facet{Param: "rating"},
+	}
+
+	// books matching the search, shared by every facet query
+	matched := fmt.Sprintf(`matched AS (SELECT books.id FROM books %s)`, bookSearchWhere)
+
+	queries := []struct {
+		facet *Facet
+		query string
+	}{
+		{
+			facet: &facets.Genre,
+			// a book counts towards its genres and all of their ancestors,
+			// the same way the genre_slug filter matches descendants
+			query: `
+				WITH RECURSIVE ` + matched + `,
+				ancestry AS (
+					SELECT bg.book_id, g.id, g.parent_id
+					FROM book_genres bg
+					INNER JOIN genres g ON g.id = bg.genre_id
+					WHERE bg.book_id IN (SELECT id FROM matched)
+					UNION
+					SELECT a.book_id, g.id, g.parent_id
+					FROM ancestry a
+					INNER JOIN genres g ON g.id = a.parent_id
+				)
+				SELECT g.slug, g.name, COUNT(DISTINCT a.book_id) AS total
+				FROM ancestry a
+				INNER JOIN genres g ON g.id = a.id
+				GROUP BY g.slug, g.name
+				ORDER BY total DESC, g.name ASC
+				LIMIT ` + fmt.Sprint(genreFacetLimit),
+		},
+		{
+			facet: &facets.Author,
+			query: `
+				WITH ` + matched + `
+				SELECT au.id::text, au.name, COUNT(DISTINCT c.book_id) AS total
+				FROM book_contributors c
+				INNER JOIN authors au ON au.id = c.author_id
+				WHERE c.role = 'author' AND c.book_id IN (SELECT id FROM matched)
+				GROUP BY au.id, au.name
+				ORDER BY total DESC, au.name ASC
+				LIMIT ` + fmt.Sprint(authorFacetLimit),
+		},
+		{
+			facet: &facets.Decade,
+			query: `
+				WITH ` + matched + `,
+				decades AS (
+					SELECT (extract(year FROM publication_date)::int / 10) * 10 AS decade
+					FROM books
+					WHERE id IN (SELECT id FROM matched) AND publication_date IS NOT NULL
+				)
+				SELECT decade::text, decade::text || 's', COUNT(*)
+				FROM decades
+				GROUP BY decade
+				ORDER BY decade DESC`,
+		},
+		{
+			facet: &facets.Rating,
+			query: `
+				WITH ` + matched + `,
+				ratings AS (
+					SELECT floor(coalesce(average_rating, 0))::int AS stars
+					FROM books
+					WHERE id IN (SELECT id FROM matched)
+				)
+				SELECT stars::text, CASE WHEN stars = 1 THEN '1 star' ELSE stars::text || ' stars' END, COUNT(*)
+				FROM ratings
+				GROUP BY stars
+				ORDER BY stars DESC`,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := search.args()
+	for _, q := range queries {
+		values, err := facetValues(ctx, m.DB, q.query, args)
+		if err != nil {
+			return nil, err
+		}
+		q.facet.Values = values
+	}
+
+	return &facets, nil
+}
+
+func facetValues(ctx context.Context, db *sql.DB, query string, args []any) ([]FacetValue, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []FacetValue{}
+	for rows.Next() {
+		var value FacetValue
+		err := rows.Scan(&value.Value, &value.Label, &value.Count)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
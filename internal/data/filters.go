@@ -11,6 +11,10 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	// Cursor continues from a next_cursor or prev_cursor instead of Page
+	Cursor string
+	// WithTotal asks for the total record count
+	WithTotal bool
 }
 
 // ValidateFilters validates the filters used for pagination and sorting.
@@ -22,6 +26,11 @@ func ValidateFilters(v *validator.Validator, f *Filters) {
 
 	// Check if the sort value is in the safelist.
 	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil && c.Sort == f.Sort, "cursor", "is invalid or does not match the sort order")
+	}
 }
 
 // Limit returns the number of records to return based on PageSize.
@@ -53,12 +62,15 @@ func (f *Filters) SortDirection() string {
 	return "ASC"
 }
 
-// Metadata holds information about pagination.
+// Metadata holds information about pagination. The totals are left out
+// when they were not counted.
 type Metadata struct {
-	CurrentPage  int `json:"current_page"`
-	PageSize     int `json:"page_size"`
-	TotalRecords int `json:"total_records"`
-	TotalPages   int `json:"total_pages"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	TotalPages   int    `json:"total_pages,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
 }
 
 // CalculateMetadata calculates pagination metadata.
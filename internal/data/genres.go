@@ -0,0 +1,371 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateSlug     = errors.New("duplicate genre slug")
+	ErrGenreHasChildren  = errors.New("genre has child genres")
+	ErrGenreCycle        = errors.New("genre cannot be its own ancestor")
+	ErrUnknownGenreSlugs = errors.New("unknown genre slug")
+	ErrParentGenre       = errors.New("parent genre does not exist")
+)
+
+// Genre is a node of the genre taxonomy.
+type Genre struct {
+	ID        int64     `json:"id"`
+	ParentID  *int64    `json:"parent_id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	Children  []*Genre  `json:"children,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"version"`
+}
+
+// BookGenre is a genre as shown on a book.
+type BookGenre struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// GenreLabel turns the name of a genre into the free text genre of a book,
+// which is limited to 50 characters while genre names may be longer.
+func GenreLabel(name string) string {
+	return truncate(name, 50)
+}
+
+// GenreModel handles the database interactions for genres.
+type GenreModel struct {
+	DB *sql.DB
+}
+
+// Slugify turns a genre name into its slug, e.g. "Science Fiction" into
+// "science-fiction". It matches the expression used by the genres migration.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 characters long")
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(len(genre.Slug) <= 100, "slug", "must not be more than 100 characters long")
+	v.Check(genre.Slug == Slugify(genre.Slug), "slug", "must only contain lower case letters, digits and single hyphens")
+	v.Check(genre.ParentID == nil || *genre.ParentID != genre.ID, "parent_id", "must not be the genre itself")
+}
+
+// BuildGenreTree nests a flat list of genres under their parents and returns
+// the top-level genres.
+func BuildGenreTree(genres []*Genre) []*Genre {
+	byID := make(map[int64]*Genre, len(genres))
+	for _, genre := range genres {
+		byID[genre.ID] = genre
+	}
+
+	roots := []*Genre{}
+	for _, genre := range genres {
+		parent, ok := byID[derefID(genre.ParentID)]
+		if genre.ParentID == nil || !ok {
+			roots = append(roots, genre)
+			continue
+		}
+		parent.Children = append(parent.Children, genre)
+	}
+	return roots
+}
+
+func derefID(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// Insert adds a new genre.
+func (m *GenreModel) Insert(genre *Genre) error {
+	query := `
+		INSERT INTO genres (parent_id, name, slug)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, genre.ParentID, genre.Name, genre.Slug).Scan(
+		&genre.ID, &genre.CreatedAt, &genre.Version,
+	)
+	return genreError(err)
+}
+
+// Get retrieves a single genre by ID, with its direct children.
+func (m *GenreModel) Get(id int64) (*Genre, error) {
+	query := `
+		SELECT id, parent_id, name, slug, created_at, version
+		FROM genres
+		WHERE id = $1 OR parent_id = $1
+		ORDER BY id = $1 DESC, name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	genres, err := m.scanGenres(m.DB.QueryContext(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+	if len(genres) == 0 || genres[0].ID != id {
+		return nil, ErrRecordNotFound
+	}
+
+	genre := genres[0]
+	genre.Children = genres[1:]
+	return genre, nil
+}
+
+// GetAll retrieves every genre ordered by name.
+func (m *GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+		SELECT id, parent_id, name, slug, created_at, version
+		FROM genres
+		ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.scanGenres(m.DB.QueryContext(ctx, query))
+}
+
+// GetBySlugs retrieves the genres with the given slugs. It returns
+// ErrUnknownGenreSlugs if any of them does not exist.
+func (m *GenreModel) GetBySlugs(slugs []string) ([]BookGenre, error) {
+	slugs = slices.Clone(slugs)
+	slices.Sort(slugs)
+	slugs = slices.Compact(slugs)
+
+	query := `
+		SELECT id, name, slug
+		FROM genres
+		WHERE slug = ANY($1)
+		ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(slugs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []BookGenre{}
+	for rows.Next() {
+		var genre BookGenre
+		err := rows.Scan(&genre.ID, &genre.Name, &genre.Slug)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, genre)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(genres) != len(slugs) {
+		return nil, ErrUnknownGenreSlugs
+	}
+	return genres, nil
+}
+
+// Update changes a genre. Moving a genre under one of its own descendants is
+// rejected with ErrGenreCycle.
+func (m *GenreModel) Update(genre *Genre) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if genre.ParentID != nil {
+		query := `
+			WITH RECURSIVE descendants AS (
+				SELECT id FROM genres WHERE id = $1
+				UNION ALL
+				SELECT g.id FROM genres g INNER JOIN descendants d ON g.parent_id = d.id
+			)
+			SELECT EXISTS (SELECT 1 FROM descendants WHERE id = $2)`
+
+		var cycle bool
+		err := m.DB.QueryRowContext(ctx, query, genre.ID, *genre.ParentID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrGenreCycle
+		}
+	}
+
+	query := `
+		UPDATE genres
+		SET parent_id = $1, name = $2, slug = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version`
+	args := []any{genre.ParentID, genre.Name, genre.Slug, genre.ID, genre.Version}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEditConfilct
+	}
+	return genreError(err)
+}
+
+// Delete removes a genre. Genres that still have children cannot be deleted.
+func (m *GenreModel) Delete(id int64) error {
+	query := `DELETE FROM genres WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return genreError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m *GenreModel) scanGenres(rows *sql.Rows, err error) ([]*Genre, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(&genre.ID, &genre.ParentID, &genre.Name, &genre.Slug, &genre.CreatedAt, &genre.Version)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+
+	return genres, rows.Err()
+}
+
+// genreError maps constraint violations on the genres table to our errors.
+func genreError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505":
+			return ErrDuplicateSlug
+		case pqErr.Code == "23503" && strings.Contains(pqErr.Detail, "is still referenced"):
+			return ErrGenreHasChildren
+		case pqErr.Code == "23503":
+			return ErrParentGenre
+		}
+	}
+	return err
+}
+
+// getBookGenres returns the genres of a book.
+func getBookGenres(db queryer, bookID int) ([]BookGenre, error) {
+	query := `
+		SELECT g.id, g.name, g.slug
+		FROM book_genres bg
+		INNER JOIN genres g ON g.id = bg.genre_id
+		WHERE bg.book_id = $1
+		ORDER BY g.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []BookGenre{}
+	for rows.Next() {
+		var genre BookGenre
+		err := rows.Scan(&genre.ID, &genre.Name, &genre.Slug)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, genre)
+	}
+
+	return genres, rows.Err()
+}
+
+// saveBookGenres replaces the genres of a book inside tx. A book that was
+// given no genres is matched to one through its free-text genre, using the
+// genre names, slugs and aliases.
+func saveBookGenres(ctx context.Context, tx *sql.Tx, book *Book) error {
+	if book.Genres == nil {
+		query := `
+			SELECT id, name, slug FROM genres
+			WHERE id = (
+				SELECT genre_id FROM genre_aliases WHERE alias = lower(trim($1))
+				UNION ALL
+				SELECT id FROM genres WHERE lower(name) = lower(trim($1)) OR slug = $2
+				LIMIT 1
+			)`
+
+		var genre BookGenre
+		err := tx.QueryRowContext(ctx, query, book.Genre, Slugify(book.Genre)).Scan(&genre.ID, &genre.Name, &genre.Slug)
+		switch {
+		case err == nil:
+			book.Genres = []BookGenre{genre}
+		case errors.Is(err, sql.ErrNoRows):
+			book.Genres = []BookGenre{}
+		default:
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM book_genres WHERE book_id = $1`, book.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, genre := range book.Genres {
+		query := `
+			INSERT INTO book_genres (book_id, genre_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`
+		_, err := tx.ExecContext(ctx, query, book.ID, genre.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
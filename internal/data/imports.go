@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// states of a book import job
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// outcome of a single imported row
+const (
+	ImportRowCreated = "created"
+	ImportRowUpdated = "updated"
+	ImportRowSkipped = "skipped"
+	ImportRowInvalid = "invalid"
+	// the row was valid but could not be written
+	ImportRowFailed = "failed"
+)
+
+// ImportRow reports what happened to one row of an uploaded file.
+type ImportRow struct {
+	Line   int               `json:"line"`
+	ISBN   string            `json:"isbn,omitempty"`
+	Status string            `json:"status"`
+	BookID int               `json:"book_id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ImportReport sums up a whole import.
+type ImportReport struct {
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Skipped int         `json:"skipped"`
+	Invalid int         `json:"invalid"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+// Add records a row and bumps the matching counter.
+func (r *ImportReport) Add(row ImportRow) {
+	switch row.Status {
+	case ImportRowCreated:
+		r.Created++
+	case ImportRowUpdated:
+		r.Updated++
+	case ImportRowSkipped:
+		r.Skipped++
+	case ImportRowInvalid:
+		r.Invalid++
+	case ImportRowFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// BookImport is an import job that a client can poll while it runs.
+type BookImport struct {
+	ID          int64         `json:"id"`
+	UserID      int           `json:"user_id"`
+	Status      string        `json:"status"`
+	DryRun      bool          `json:"dry_run"`
+	OnDuplicate string        `json:"on_duplicate"`
+	TotalRows   int           `json:"total_rows"`
+	Report      *ImportReport `json:"report,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+}
+
+// ImportModel handles the database interactions for import jobs.
+type ImportModel struct {
+	DB *sql.DB
+}
+
+// ValidateImportOptions checks the options a client passed for an import.
+func ValidateImportOptions(v *validator.Validator, format string, onDuplicate string) {
+	v.Check(validator.In(format, "csv", "ndjson"), "format", "must be either 'csv' or 'ndjson'")
+	v.Check(validator.In(onDuplicate, "skip", "update"), "on_duplicate", "must be either 'skip' or 'update'")
+}
+
+// Insert creates a new running import job.
+func (m *ImportModel) Insert(job *BookImport) error {
+	query := `
+		INSERT INTO book_imports (user_id, status, dry_run, on_duplicate, total_rows)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+	args := []any{job.UserID, job.Status, job.DryRun, job.OnDuplicate, job.TotalRows}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&job.ID, &job.CreatedAt)
+}
+
+// Get retrieves an import job by ID.
+func (m *ImportModel) Get(id int64) (*BookImport, error) {
+	query := `
+		SELECT id, user_id, status, dry_run, on_duplicate, total_rows, report, error, created_at, completed_at
+		FROM book_imports
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var job BookImport
+	var report []byte
+	var jobError sql.NullString
+	var completedAt sql.NullTime
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Status,
+		&job.DryRun,
+		&job.OnDuplicate,
+		&job.TotalRows,
+		&report,
+		&jobError,
+		&job.CreatedAt,
+		&completedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if report != nil {
+		job.Report = &ImportReport{}
+		err = json.Unmarshal(report, job.Report)
+		if err != nil {
+			return nil, err
+		}
+	}
+	job.Error = jobError.String
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+
+	return &job, nil
+}
+
+// Complete stores the final report of a job.
+func (m *ImportModel) Complete(job *BookImport) error {
+	report, err := json.Marshal(job.Report)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE book_imports
+		SET status = $1, report = $2, completed_at = NOW()
+		WHERE id = $3
+		RETURNING completed_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	job.Status = ImportCompleted
+	return m.DB.QueryRowContext(ctx, query, job.Status, report, job.ID).Scan(&job.CompletedAt)
+}
+
+// Fail marks a job as failed with the error that stopped it. The report,
+// when there is one, is stored as well so the rows already written are
+// known.
+func (m *ImportModel) Fail(job *BookImport, jobErr error) error {
+	var report []byte
+	if job.Report != nil {
+		var err error
+		report, err = json.Marshal(job.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE book_imports
+		SET status = $1, error = $2, report = $3, completed_at = NOW()
+		WHERE id = $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	job.Status = ImportFailed
+	job.Error = jobErr.Error()
+	_, err := m.DB.ExecContext(ctx, query, job.Status, job.Error, report, job.ID)
+	return err
+}
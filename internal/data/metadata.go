@@ -0,0 +1,71 @@
+package data
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrMetadataNotFound = errors.New("no metadata found for isbn")
+
+// ErrMetadataUnavailable is returned by a provider that cannot answer yet,
+// such as one whose index is still being built.
+var ErrMetadataUnavailable = errors.New("metadata provider is not available")
+
+// BookMetadata is what a metadata provider knows about an edition.
+type BookMetadata struct {
+	Title           string
+	Authors         []string
+	PublicationDate time.Time
+	Genre           string
+	Description     string
+}
+
+// MetadataProvider looks up book details by ISBN. Implementations return
+// ErrMetadataNotFound when they have no record for the ISBN and
+// ErrMetadataUnavailable when they cannot look anything up at the moment.
+type MetadataProvider interface {
+	LookupISBN(isbn13 string) (*BookMetadata, error)
+}
+
+// Enrich fills the empty fields of the book from meta and returns the JSON
+// names of the fields it changed. Fields that already have a value are left
+// alone.
+func (b *Book) Enrich(meta *BookMetadata) []string {
+	filled := []string{}
+
+	if b.Title == "" && meta.Title != "" {
+		b.Title = meta.Title
+		filled = append(filled, "title")
+	}
+	if len(b.Authors) == 0 && len(meta.Authors) > 0 {
+		b.Authors = meta.Authors
+		filled = append(filled, "authors")
+	}
+	if b.PublicationDate.IsZero() && !meta.PublicationDate.IsZero() {
+		b.PublicationDate = meta.PublicationDate
+		filled = append(filled, "publication_date")
+	}
+	if b.Genre == "" && meta.Genre != "" {
+		b.Genre = truncate(meta.Genre, 50)
+		filled = append(filled, "genre")
+	}
+	if b.Description == "" && meta.Description != "" {
+		b.Description = truncate(meta.Description, 1000)
+		filled = append(filled, "description")
+	}
+
+	return filled
+}
+
+// truncate shortens s to at most max bytes without splitting a character.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
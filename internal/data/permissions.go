@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// permission codes
+const (
+	PermissionCatalogAdmin  = "catalog:admin"
+	PermissionModerateBooks = "books:moderate"
+)
+
+// PermissionCodes are all the permission codes there are.
+var PermissionCodes = []string{PermissionCatalogAdmin, PermissionModerateBooks}
+
+// Permissions holds the permission codes granted to a user.
+type Permissions []string
+
+// Include checks if the user has been granted a permission.
+func (p Permissions) Include(code string) bool {
+	return slices.Contains(p, code)
+}
+
+// PermissionModel handles the database interactions for permissions.
+type PermissionModel struct {
+	DB *sql.DB
+}
+
+// GetAllForUser returns the permission codes granted to a user.
+func (m *PermissionModel) GetAllForUser(userID int) (Permissions, error) {
+	query := `
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		WHERE users_permissions.user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var code string
+		err := rows.Scan(&code)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, code)
+	}
+
+	return permissions, rows.Err()
+}
+
+// AddForUser grants permissions to a user.
+func (m *PermissionModel) AddForUser(userID int, codes ...string) error {
+	query := `
+		INSERT INTO users_permissions
+		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
+}
@@ -0,0 +1,115 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Publisher is a publishing house shared by the books it published.
+type Publisher struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	BookCount int       `json:"book_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PublisherModel handles the database interactions for publishers.
+type PublisherModel struct {
+	DB *sql.DB
+}
+
+// legalForms are the company suffixes ignored when comparing publisher
+// names.
+var legalForms = []string{"inc", "incorporated", "ltd", "limited", "llc", "co", "corp", "corporation", "plc", "gmbh", "sa"}
+
+// NormalizePublisherName reduces a name to lower case letters and digits
+// without a trailing legal form, so "Penguin Books Ltd." and "Penguin Books"
+// are the same publisher. It matches the normalized_name column of the
+// publishers table.
+func NormalizePublisherName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 && slices.Contains(legalForms, words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, "")
+}
+
+// savePublisher points the book at the publisher named in book.Publisher
+// inside tx, creating the publisher when it does not exist yet. An empty
+// name clears the publisher.
+func savePublisher(ctx context.Context, tx *sql.Tx, book *Book) error {
+	name := strings.TrimSpace(book.Publisher)
+	normalized := NormalizePublisherName(name)
+	if normalized == "" {
+		book.PublisherID = nil
+		book.Publisher = ""
+		return nil
+	}
+
+	// the no-op update lets RETURNING hand back the id of an existing publisher
+	query := `
+		INSERT INTO publishers (name, normalized_name)
+		VALUES ($1, $2)
+		ON CONFLICT (normalized_name) DO UPDATE SET normalized_name = EXCLUDED.normalized_name
+		RETURNING id, name`
+	var id int64
+	err := tx.QueryRowContext(ctx, query, name, normalized).Scan(&id, &book.Publisher)
+	if err != nil {
+		return err
+	}
+	book.PublisherID = &id
+	return nil
+}
+
+// GetAll retrieves the publishers, optionally filtered by name.
+func (m *PublisherModel) GetAll(name string, filters Filters) ([]*Publisher, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), p.id, p.name, p.created_at,
+			(SELECT COUNT(*) FROM books WHERE books.publisher_id = p.id AND books.deleted_at IS NULL)
+		FROM publishers p
+		WHERE ($1 = '' OR p.name ILIKE '%%' || $1 || '%%')
+		ORDER BY %s %s, p.id ASC
+		LIMIT $2 OFFSET $3`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	publishers := []*Publisher{}
+
+	for rows.Next() {
+		var publisher Publisher
+		err := rows.Scan(
+			&totalRecords,
+			&publisher.ID,
+			&publisher.Name,
+			&publisher.CreatedAt,
+			&publisher.BookCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		publishers = append(publishers, &publisher)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return publishers, metadata, nil
+}
@@ -0,0 +1,170 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// The rankings kept in book_rankings.
+const (
+	RankingTop      = "top"
+	RankingTrending = "trending"
+)
+
+// RankingWindows are the time windows every ranking is computed over. all
+// takes every review and list entry into account.
+var RankingWindows = []string{"week", "month", "year", "all"}
+
+// rankingWindows gives the span of each window as an interval, and the
+// half-life trending activity decays with inside it.
+var rankingWindows = map[string]struct {
+	span     *string
+	halfLife string
+}{
+	"week":  {span: ptr("7 days"), halfLife: "2 days"},
+	"month": {span: ptr("30 days"), halfLife: "7 days"},
+	"year":  {span: ptr("365 days"), halfLife: "60 days"},
+	"all":   {span: nil, halfLife: "180 days"},
+}
+
+// topPriorWeight is the number of average reviews every book is assumed to
+// start with in the top ranking, so a handful of perfect scores does not
+// outrank a long record of very good ones.
+const topPriorWeight = 10
+
+// trendingReviewWeight is how much more a review counts towards trending
+// than adding the book to a reading list.
+const trendingReviewWeight = 2
+
+// RankedBook is a book with its score in a ranking.
+type RankedBook struct {
+	Score float64 `json:"score"`
+	*Book
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// RefreshRankings recomputes every ranking in every window from the reviews
+// and reading list entries, replacing the previous scores in one go.
+//
+// The top ranking is a Bayesian average: each book's ratings in the window
+// are pulled towards the mean of all ratings in the window by
+// topPriorWeight reviews. The trending ranking sums the reviews and list
+// additions in the window, each weighted down by half for every half-life
+// of the window that has passed since.
+func (m *BookModel) RefreshRankings() error {
+	top := `
+		WITH recent AS (
+			SELECT book_id, rating
+			FROM boo_reviews
+			WHERE deleted_at IS NULL
+			AND ($2::interval IS NULL OR created_at >= NOW() - $2::interval)
+		),
+		prior AS (
+			SELECT coalesce(avg(rating), 0) AS mean FROM recent
+		)
+		INSERT INTO book_rankings (ranking, time_window, book_id, score)
+		SELECT 'top', $1::text, recent.book_id, ($3::numeric * prior.mean + sum(recent.rating)) / ($3::numeric + count(*))
+		FROM recent, prior
+		GROUP BY recent.book_id, prior.mean`
+
+	trending := `
+		WITH events AS (
+			SELECT book_id, created_at AS happened_at, $4::float AS weight
+			FROM boo_reviews
+			WHERE deleted_at IS NULL
+			AND ($2::interval IS NULL OR created_at >= NOW() - $2::interval)
+			UNION ALL
+			SELECT book_id, added_at, 1
+			FROM book_lists
+			WHERE added_at IS NOT NULL
+			AND ($2::interval IS NULL OR added_at >= NOW() - $2::interval)
+		)
+		INSERT INTO book_rankings (ranking, time_window, book_id, score)
+		SELECT 'trending', $1::text, book_id,
+			sum(weight * power(0.5, extract(epoch FROM NOW() - happened_at) / extract(epoch FROM $3::interval)))
+		FROM events
+		GROUP BY book_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM book_rankings`)
+	if err != nil {
+		return err
+	}
+
+	for _, window := range RankingWindows {
+		w := rankingWindows[window]
+		_, err = tx.ExecContext(ctx, top, window, w.span, topPriorWeight)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, trending, window, w.span, w.halfLife, trendingReviewWeight)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Rankings lists the books of a ranking in the given window, best first. A
+// genre slug limits them to the genre and its descendants.
+func (m *BookModel) Rankings(ranking, window, genreSlug string, filters Filters) ([]*RankedBook, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), rk.score, ` + bookColumns + `
+		FROM book_rankings rk
+		INNER JOIN books ON books.id = rk.book_id
+		WHERE rk.ranking = $1 AND rk.time_window = $2
+		AND books.deleted_at IS NULL
+		AND ($3 = '' OR books.id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM genres WHERE slug = $3
+				UNION ALL
+				SELECT g.id FROM genres g INNER JOIN tree ON g.parent_id = tree.id
+			)
+			SELECT bg.book_id FROM book_genres bg INNER JOIN tree ON tree.id = bg.genre_id
+		))
+		ORDER BY rk.score DESC, books.id ASC
+		LIMIT $4 OFFSET $5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, ranking, window, genreSlug, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	books := []*RankedBook{}
+
+	for rows.Next() {
+		book := RankedBook{Book: &Book{}}
+		dest := append([]any{&totalRecords, &book.Score}, book.scanTargets()...)
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		book.setDerivedFields()
+		books = append(books, &book)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return books, metadata, nil
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// refreshRatingsQuery recomputes the rating aggregates of the books in $1 from
+// their live reviews, or of every book when $1 is NULL.
+const refreshRatingsQuery = `
+	UPDATE books
+	SET average_rating = coalesce(s.average, 0),
+		review_count = s.total,
+		rating_histogram = s.histogram
+	FROM (
+		SELECT b.id,
+			round(avg(r.rating), 2) AS average,
+			count(r.id) AS total,
+			ARRAY[
+				count(r.id) FILTER (WHERE r.rating = 1),
+				count(r.id) FILTER (WHERE r.rating = 2),
+				count(r.id) FILTER (WHERE r.rating = 3),
+				count(r.id) FILTER (WHERE r.rating = 4),
+				count(r.id) FILTER (WHERE r.rating = 5)
+			]::int[] AS histogram
+		FROM books b
+		LEFT JOIN boo_reviews r ON r.book_id = b.id AND r.deleted_at IS NULL
+		WHERE $1::bigint[] IS NULL OR b.id = ANY($1)
+		GROUP BY b.id
+	) s
+	WHERE books.id = s.id`
+
+// refreshRatings brings the rating aggregates of the given books up to date.
+// The book version is left alone, so a new review does not turn a pending
+// edit of the book into a conflict.
+func refreshRatings(ctx context.Context, db execer, bookIDs ...int64) error {
+	_, err := db.ExecContext(ctx, refreshRatingsQuery, pq.Array(bookIDs))
+	return err
+}
+
+// RecomputeRatings rebuilds the rating aggregates of every book from its
+// reviews and returns the number of books updated.
+func (m *BookModel) RecomputeRatings() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, refreshRatingsQuery, nil)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// histogramFrom turns the stored per-star counts into a map keyed by star.
+func histogramFrom(counts []int64) map[int]int {
+	histogram := make(map[int]int, 5)
+	for stars := 1; stars <= 5; stars++ {
+		histogram[stars] = 0
+		if stars <= len(counts) {
+			histogram[stars] = int(counts[stars-1])
+		}
+	}
+	return histogram
+}
@@ -3,10 +3,12 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/RayMC17/bookclub-api/internal/validator"
+	"github.com/lib/pq"
 )
 
 // ReadingList represents a reading list in the book club system.
@@ -57,7 +59,7 @@ func (m *ReadingListModel) Get(id int) (*ReadingList, error) {
 	query := `
 		SELECT id, name, description, created_by, created_at, version
 		FROM lists_names
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	var list ReadingList
 
@@ -77,31 +79,38 @@ func (m *ReadingListModel) Get(id int) (*ReadingList, error) {
 	return &list, nil
 }
 
-// Update an existing reading list
+// Update an existing reading list. It returns ErrEditConfilct when the list
+// has changed since it was read.
 func (m *ReadingListModel) Update(list *ReadingList) error {
 	query := `
 		UPDATE lists_names
 		SET name = $1, description = $2, version = version+1
-		WHERE id = $3 AND version = $4
+		WHERE id = $3 AND version = $4 AND deleted_at IS NULL
 		RETURNING version`
 	args := []interface{}{list.Name, list.Description, list.ID, list.Version}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
 		&list.Version,
 	)
-
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEditConfilct
+	}
+	return err
 }
 
-// Delete a reading list by ID
-func (m *ReadingListModel) Delete(id int) error {
-	query := `DELETE FROM lists_names WHERE id = $1`
+// Delete moves a reading list to the trash
+func (m *ReadingListModel) Delete(id int, deletedBy int) error {
+	query := `
+		UPDATE lists_names
+		SET deleted_at = NOW(), deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, deletedBy)
 	if err != nil {
 		return err
 	}
@@ -158,28 +167,35 @@ func (m *ReadingListModel) RemoveBook(readingListID int, bookID int) error {
 
 // GetAll retrieves all reading lists based on the filters.
 func (m *ReadingListModel) GetAll(filters Filters) ([]*ReadingList, Metadata, error) {
+	ks, err := newKeyset(filters, filters.SortColumn(), "id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	cond, tail, pageArgs, err := ks.clause(1)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
 	query := fmt.Sprintf(`
-        SELECT COUNT(*) OVER(), id, name, description, created_by, created_at, version
+        SELECT id, name, description, created_by, created_at, version
         FROM lists_names
-        ORDER BY %s %s
-        LIMIT $1 OFFSET $2`, filters.SortColumn(), filters.SortDirection())
+        WHERE deleted_at IS NULL AND %s
+        %s`, cond, tail)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, filters.Limit(), filters.Offset())
+	rows, err := m.DB.QueryContext(ctx, query, pageArgs...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
-	totalRecords := 0
 	var readingLists []*ReadingList
 
 	for rows.Next() {
 		var readingList ReadingList
 		err := rows.Scan(
-			&totalRecords,
 			&readingList.ID,
 			&readingList.Name,
 			&readingList.Description,
@@ -197,7 +213,21 @@ func (m *ReadingListModel) GetAll(filters Filters) ([]*ReadingList, Metadata, er
 		return nil, Metadata{}, err
 	}
 
-	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	totalRecords := 0
+	if filters.WithTotal {
+		err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM lists_names WHERE deleted_at IS NULL`).Scan(&totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	readingLists, more := keysetPage(ks, readingLists)
+	metadata := ks.metadata(totalRecords, more, len(readingLists), func(i int) (any, int64) {
+		if filters.SortColumn() == "name" {
+			return readingLists[i].Name, int64(readingLists[i].ID)
+		}
+		return readingLists[i].ID, int64(readingLists[i].ID)
+	})
 	return readingLists, metadata, nil
 }
 
@@ -205,7 +235,7 @@ func (m *ReadingListModel) GetAllByUser(userID int64) ([]*ReadingList, error) {
 	query := `
         SELECT id, name, description, created_at, created_by, version
         FROM lists_names
-        WHERE created_by = $1
+        WHERE created_by = $1 AND deleted_at IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -261,7 +291,7 @@ func (m *ReadingListModel) ReadingListExist(id int) error {
 	query := `
 	SELECT id
 	FROM lists_names
-	WHERE id = $1`
+	WHERE id = $1 AND deleted_at IS NULL`
 
 	var list ReadingList
 
@@ -276,3 +306,47 @@ func (m *ReadingListModel) ReadingListExist(id int) error {
 	}
 	return nil
 }
+
+// ListedBook is a book as it appears in a reading list.
+type ListedBook struct {
+	*Book
+	Status string `json:"status"`
+}
+
+// GetBooks loads the books of every given list in one query, keyed by list
+// id. Deleted books are left out.
+func (m *ReadingListModel) GetBooks(listIDs []int) (map[int][]*ListedBook, error) {
+	books := make(map[int][]*ListedBook, len(listIDs))
+	if len(listIDs) == 0 {
+		return books, nil
+	}
+
+	query := `
+	SELECT bl.list_name, bl.status, ` + bookColumns + `
+	FROM book_lists bl
+	INNER JOIN books ON books.id = bl.book_id
+	WHERE bl.list_name = ANY($1) AND books.deleted_at IS NULL
+	ORDER BY bl.list_name, books.title, books.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(listIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var listID int
+		entry := ListedBook{Book: &Book{}}
+		err := rows.Scan(append([]any{&listID, &entry.Status}, entry.scanTargets()...)...)
+		if err != nil {
+			return nil, err
+		}
+		entry.setDerivedFields()
+		books[listID] = append(books[listID], &entry)
+	}
+
+	return books, rows.Err()
+}
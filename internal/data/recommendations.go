@@ -0,0 +1,212 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Recommendations are item to item: two books are similar when the same
+// readers liked them, where liking a book means rating it 4 or more or
+// putting it on one of their reading lists. The similarity of two books is
+// the cosine of their reader sets, the number of readers they share divided
+// by the geometric mean of their reader counts.
+
+const (
+	// similarityMinShared is the number of readers two books must share
+	// before they count as similar, so one reader's taste is not enough.
+	similarityMinShared = 2
+	// similarityNeighbours is the number of similar books kept per book.
+	similarityNeighbours = 50
+)
+
+// likedBooks selects the (user_id, book_id) pairs the similarity is built
+// from.
+const likedBooks = `
+	SELECT user_id, book_id
+	FROM boo_reviews
+	WHERE rating >= 4 AND deleted_at IS NULL
+	UNION
+	SELECT ln.created_by, bl.book_id
+	FROM book_lists bl
+	INNER JOIN lists_names ln ON ln.id = bl.list_name
+	WHERE ln.created_by IS NOT NULL AND ln.deleted_at IS NULL`
+
+// seenBooks selects the books user $1 has reviewed or listed, which are
+// never recommended to them.
+const seenBooks = `
+	SELECT book_id FROM boo_reviews WHERE user_id = $1 AND deleted_at IS NULL
+	UNION
+	SELECT bl.book_id
+	FROM book_lists bl
+	INNER JOIN lists_names ln ON ln.id = bl.list_name
+	WHERE ln.created_by = $1 AND ln.deleted_at IS NULL`
+
+// Recommendation is a suggested book with its score and a short reason.
+type Recommendation struct {
+	Score   float64 `json:"score"`
+	Because string  `json:"because"`
+	*Book
+}
+
+// RefreshSimilarity rebuilds the book similarity table from the current
+// reviews and reading lists.
+func (m *BookModel) RefreshSimilarity() error {
+	query := `
+		WITH liked AS (` + likedBooks + `
+		),
+		readers AS (
+			SELECT book_id, count(*) AS total FROM liked GROUP BY book_id
+		),
+		pairs AS (
+			SELECT a.book_id, b.book_id AS similar_id, count(*) AS shared
+			FROM liked a
+			INNER JOIN liked b ON b.user_id = a.user_id AND b.book_id <> a.book_id
+			GROUP BY a.book_id, b.book_id
+			HAVING count(*) >= $1
+		),
+		scored AS (
+			SELECT p.book_id, p.similar_id, p.shared, p.shared / sqrt(ra.total * rb.total) AS score
+			FROM pairs p
+			INNER JOIN readers ra ON ra.book_id = p.book_id
+			INNER JOIN readers rb ON rb.book_id = p.similar_id
+		),
+		ranked AS (
+			SELECT *, row_number() OVER (PARTITION BY book_id ORDER BY score DESC, similar_id ASC) AS position
+			FROM scored
+		)
+		INSERT INTO book_similarity (book_id, similar_id, score, shared)
+		SELECT book_id, similar_id, score, shared
+		FROM ranked
+		WHERE position <= $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM book_similarity`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query, similarityMinShared, similarityNeighbours)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Similar lists the books most similar to a book, leaving out the ones user
+// userID has already reviewed or listed.
+func (m *BookModel) Similar(bookID, userID int, filters Filters) ([]*Recommendation, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), s.score, s.shared, NULL::bool, source.title, ` + bookColumns + `
+		FROM book_similarity s
+		INNER JOIN books source ON source.id = s.book_id
+		INNER JOIN books ON books.id = s.similar_id
+		WHERE s.book_id = $2 AND books.deleted_at IS NULL
+		AND s.similar_id NOT IN (` + seenBooks + `
+		)
+		ORDER BY s.score DESC, books.id ASC
+		LIMIT $3 OFFSET $4`
+
+	return m.recommendations(query, filters, userID, bookID)
+}
+
+// Recommendations suggests books to a user from the books they liked, each
+// with the liked book that contributed most to the suggestion.
+func (m *BookModel) Recommendations(userID int, filters Filters) ([]*Recommendation, Metadata, error) {
+	query := `
+		WITH seeds AS (
+			-- a book both rated highly and listed is explained by the rating
+			SELECT DISTINCT ON (book_id) book_id, rated
+			FROM (
+				SELECT book_id, true AS rated
+				FROM boo_reviews
+				WHERE user_id = $1 AND rating >= 4 AND deleted_at IS NULL
+				UNION ALL
+				SELECT bl.book_id, false
+				FROM book_lists bl
+				INNER JOIN lists_names ln ON ln.id = bl.list_name
+				WHERE ln.created_by = $1 AND ln.deleted_at IS NULL
+			) liked
+			WHERE book_id IN (SELECT id FROM books WHERE deleted_at IS NULL)
+			ORDER BY book_id, rated DESC
+		),
+		candidates AS (
+			SELECT s.similar_id,
+				sum(s.score) AS score,
+				(array_agg(s.book_id ORDER BY s.score DESC, seeds.rated DESC))[1] AS because_id,
+				(array_agg(seeds.rated ORDER BY s.score DESC, seeds.rated DESC))[1] AS rated
+			FROM seeds
+			INNER JOIN book_similarity s ON s.book_id = seeds.book_id
+			WHERE s.similar_id NOT IN (` + seenBooks + `
+			)
+			GROUP BY s.similar_id
+		)
+		SELECT COUNT(*) OVER(), c.score, 0, c.rated, because.title, ` + bookColumns + `
+		FROM candidates c
+		INNER JOIN books because ON because.id = c.because_id
+		INNER JOIN books ON books.id = c.similar_id
+		WHERE books.deleted_at IS NULL
+		ORDER BY c.score DESC, books.id ASC
+		LIMIT $2 OFFSET $3`
+
+	return m.recommendations(query, filters, userID)
+}
+
+// recommendations runs the query of Similar or Recommendations. Ahead of
+// bookColumns it selects the total, the score, the readers shared with the
+// source book for Similar, whether the source book was rated rather than
+// listed for Recommendations, and the title of the source book.
+func (m *BookModel) recommendations(query string, filters Filters, args ...any) ([]*Recommendation, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args = append(args, filters.Limit(), filters.Offset())
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	recommendations := []*Recommendation{}
+
+	for rows.Next() {
+		rec := Recommendation{Book: &Book{}}
+		var shared int
+		var rated sql.NullBool
+		var title string
+		dest := append([]any{&totalRecords, &rec.Score, &shared, &rated, &title}, rec.scanTargets()...)
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		rec.setDerivedFields()
+		switch {
+		case !rated.Valid:
+			rec.Because = fmt.Sprintf("%d readers who liked %s also liked this", shared, title)
+		case rated.Bool:
+			rec.Because = fmt.Sprintf("because you rated %s highly", title)
+		default:
+			rec.Because = fmt.Sprintf("because you added %s to a reading list", title)
+		}
+		recommendations = append(recommendations, &rec)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return recommendations, metadata, nil
+}
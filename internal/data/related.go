@@ -0,0 +1,122 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Content similarity compares books by the words of their title, authors,
+// genre and description, so books nobody has reviewed yet still have related
+// books. Each book is a TF-IDF vector over the terms in book_terms: a term
+// weighs 1 + ln(occurrences) times ln(books / books with the term) + 1.
+// Related books are the nearest by cosine similarity. Only the term counts
+// are stored, the IDF is worked out when reading so it follows the catalog
+// as it grows.
+
+// relatedCandidates is the number of books sharing the most weight with a
+// book that are scored in full.
+const relatedCandidates = 200
+
+// saveBookTerms replaces the stored terms of a book with those of its
+// current title, authors, genre and description inside tx.
+func saveBookTerms(ctx context.Context, tx *sql.Tx, bookID int) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM book_terms WHERE book_id = $1`, bookID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO book_terms (book_id, term, weight)
+		SELECT books.id, w.term, w.weight
+		FROM books, book_term_weights(books.title, books.authors, books.genre, books.description) w
+		WHERE books.id = $1`
+	_, err = tx.ExecContext(ctx, query, bookID)
+	return err
+}
+
+// Related lists the books whose content is closest to a book's, closest
+// first.
+func (m *BookModel) Related(bookID int, filters Filters) ([]*RankedBook, Metadata, error) {
+	query := `
+		WITH catalog AS (
+			SELECT greatest(count(*), 1)::float8 AS books FROM books WHERE deleted_at IS NULL
+		),
+		idf AS (
+			SELECT term, ln(catalog.books / count(*)) + 1 AS idf
+			FROM book_terms, catalog
+			WHERE term IN (SELECT term FROM book_terms WHERE book_id = $1)
+			GROUP BY term, catalog.books
+		),
+		source AS (
+			SELECT bt.term, (1 + ln(bt.weight)) * idf.idf AS w
+			FROM book_terms bt
+			INNER JOIN idf ON idf.term = bt.term
+			WHERE bt.book_id = $1
+		),
+		-- the dot product only involves the terms a book shares with the
+		-- source, so it picks the candidates before any other vector is built
+		candidates AS (
+			SELECT bt.book_id, sum((1 + ln(bt.weight)) * idf.idf * source.w) AS dot
+			FROM book_terms bt
+			INNER JOIN source ON source.term = bt.term
+			INNER JOIN idf ON idf.term = bt.term
+			INNER JOIN books ON books.id = bt.book_id
+			WHERE bt.book_id <> $1 AND books.deleted_at IS NULL
+			GROUP BY bt.book_id
+			ORDER BY dot DESC
+			LIMIT $2
+		),
+		norms AS (
+			SELECT t.book_id, sqrt(sum(power((1 + ln(t.weight)) * (ln(catalog.books / df.books) + 1), 2))) AS norm
+			FROM book_terms t
+			INNER JOIN (
+				SELECT term, count(*) AS books
+				FROM book_terms
+				WHERE term IN (SELECT term FROM book_terms WHERE book_id IN (SELECT book_id FROM candidates))
+				GROUP BY term
+			) df ON df.term = t.term
+			CROSS JOIN catalog
+			WHERE t.book_id IN (SELECT book_id FROM candidates)
+			GROUP BY t.book_id
+		)
+		SELECT COUNT(*) OVER(), c.dot / nullif(n.norm * (SELECT sqrt(sum(w * w)) FROM source), 0) AS score, ` + bookColumns + `
+		FROM candidates c
+		INNER JOIN norms n ON n.book_id = c.book_id
+		INNER JOIN books ON books.id = c.book_id
+		ORDER BY score DESC NULLS LAST, books.id ASC
+		LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, bookID, relatedCandidates, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	books := []*RankedBook{}
+
+	for rows.Next() {
+		book := RankedBook{Book: &Book{}}
+		var score sql.NullFloat64
+		dest := append([]any{&totalRecords, &score}, book.scanTargets()...)
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		book.Score = score.Float64
+		book.setDerivedFields()
+		books = append(books, &book)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return books, metadata, nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/RayMC17/bookclub-api/internal/validator"
+	"github.com/lib/pq"
 )
 
 var ErrNoRecord = errors.New("record not found")
@@ -36,7 +37,8 @@ func ValidateReview(v *validator.Validator, review *Review) {
 	v.Check(len(review.Content) <= 1000, "content", "must not be more than 1000 characters long")
 }
 
-// Insert adds a new review to the database.
+// Insert adds a new review to the database and updates the rating
+// aggregates of its book.
 func (m *ReviewModel) Insert(review *Review) error {
 	query := `
         INSERT INTO boo_reviews (book_id, user_id, rating, review_text)
@@ -45,7 +47,26 @@ func (m *ReviewModel) Insert(review *Review) error {
 
 	args := []interface{}{review.BookID, review.AuthorID, review.Rating, review.Content}
 
-	return m.DB.QueryRow(query, args...).Scan(&review.ID, &review.CreatedAt, &review.Version)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.Version)
+	if err != nil {
+		return err
+	}
+
+	err = refreshRatings(ctx, tx, review.BookID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Get retrieves a specific review by ID.
@@ -53,7 +74,8 @@ func (m *ReviewModel) Get(id int64) (*Review, error) {
 	query := `
         SELECT id, book_id, user_id, rating, review_text, created_at, version
         FROM boo_reviews
-        WHERE id = $1`
+        WHERE id = $1 AND deleted_at IS NULL
+        AND book_id IN (SELECT id FROM books WHERE deleted_at IS NULL)`
 
 	var review Review
 
@@ -76,44 +98,76 @@ func (m *ReviewModel) Get(id int64) (*Review, error) {
 	return &review, nil
 }
 
-// Update modifies the data of a specific review.
+// Update modifies the data of a specific review and updates the rating
+// aggregates of its book. It returns ErrEditConfilct when the review has
+// changed since it was read.
 func (m *ReviewModel) Update(review *Review) error {
 	query := `
         UPDATE boo_reviews
         SET rating = $1, review_text = $2, version = version+1
-        WHERE id = $3
+        WHERE id = $3 AND version = $4 AND deleted_at IS NULL
         RETURNING version`
 
-	args := []interface{}{review.Rating, review.Content, review.ID}
+	args := []interface{}{review.Rating, review.Content, review.ID, review.Version}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&review.Version)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEditConfilct
+	}
+	if err != nil {
+		return err
+	}
+
+	err = refreshRatings(ctx, tx, review.BookID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Delete removes a specific review from the database.
-func (m *ReviewModel) Delete(id int64) error {
+// Delete moves a specific review to the trash and drops it from the rating
+// aggregates of its book.
+func (m *ReviewModel) Delete(id int64, deletedBy int) error {
 	query := `
-        DELETE FROM boo_reviews
-        WHERE id = $1`
+        UPDATE boo_reviews
+        SET deleted_at = NOW(), deleted_by = $2
+        WHERE id = $1 AND deleted_at IS NULL
+        RETURNING book_id`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	//check if any rows affected
-	rowsAffected, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	var bookID int64
+	err = tx.QueryRowContext(ctx, query, id, deletedBy).Scan(&bookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound //no rows affected
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound //no rows affected
+	err = refreshRatings(ctx, tx, bookID)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	return tx.Commit()
 }
 
 // GetAll retrieves all reviews for a specific book with optional filters for pagination and sorting.
@@ -121,7 +175,7 @@ func (m *ReviewModel) GetAll(bookID int64) ([]*Review, error) {
 	query := `
         SELECT id, book_id, user_id, rating, review_text, created_at, version
         FROM boo_reviews
-        WHERE book_id = $1
+        WHERE book_id = $1 AND deleted_at IS NULL
     `
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -160,6 +214,50 @@ func (m *ReviewModel) GetAll(bookID int64) ([]*Review, error) {
 	return reviews, nil
 }
 
+// GetAllForBooks loads the reviews of every given book in one query, keyed
+// by book id.
+func (m *ReviewModel) GetAllForBooks(bookIDs []int64) (map[int64][]*Review, error) {
+	reviews := make(map[int64][]*Review, len(bookIDs))
+	if len(bookIDs) == 0 {
+		return reviews, nil
+	}
+
+	query := `
+        SELECT id, book_id, user_id, rating, review_text, created_at, version
+        FROM boo_reviews
+        WHERE book_id = ANY($1) AND deleted_at IS NULL
+        ORDER BY book_id, created_at DESC, id DESC
+    `
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(bookIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&review.ID,
+			&review.BookID,
+			&review.AuthorID,
+			&review.Rating,
+			&review.Content,
+			&review.CreatedAt,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reviews[review.BookID] = append(reviews[review.BookID], &review)
+	}
+
+	return reviews, rows.Err()
+}
+
 // Helper function to safely format the SQL query with sort options.
 // func formatQuery(query, sortColumn, sortDirection string) string {
 // 	return fmt.Sprintf(query, sortColumn, sortDirection)
@@ -169,7 +267,8 @@ func (m *ReviewModel) GetAllByUser(userID int64) ([]*Review, error) {
 	query := `
         SELECT id, book_id, user_id, review_text, rating, version
         FROM boo_reviews
-        WHERE user_id = $1
+        WHERE user_id = $1 AND deleted_at IS NULL
+        AND book_id IN (SELECT id FROM books WHERE deleted_at IS NULL)
     `
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -0,0 +1,333 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BookState is the editable content of a book as kept in its revisions.
+type BookState struct {
+	Title           string   `json:"title"`
+	Authors         []string `json:"authors"`
+	ISBN            string   `json:"isbn"`
+	PublicationDate string   `json:"publication_date"`
+	Genre           string   `json:"genre"`
+	Description     string   `json:"description"`
+	Publisher       string   `json:"publisher"`
+	Language        string   `json:"language"`
+	Pages           *int     `json:"pages"`
+	Format          string   `json:"format"`
+	DurationMinutes *int     `json:"duration_minutes"`
+	OriginalYear    *int     `json:"original_year"`
+	// Contributors holds the contributors other than the authors
+	Contributors []Credit `json:"contributors"`
+	// Genres are genre slugs
+	Genres []string `json:"genres"`
+}
+
+// Credit is a contributor as kept in a revision.
+type Credit struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// bookStateFields are the fields of BookState in the order changes are
+// reported.
+var bookStateFields = []string{"title", "authors", "isbn", "publication_date", "genre", "description",
+	"publisher", "language", "pages", "format", "duration_minutes", "original_year", "contributors", "genres"}
+
+// FieldChange is one field that differs between a revision and the one
+// before it.
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// BookRevision is a recorded edit of a book.
+type BookRevision struct {
+	Revision     int           `json:"revision"`
+	BookID       int64         `json:"book_id"`
+	EditorID     *int          `json:"editor_id"`
+	Editor       string        `json:"editor,omitempty"`
+	RevertedFrom *int          `json:"reverted_from,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Changes      []FieldChange `json:"changes"`
+	State        *BookState    `json:"-"`
+}
+
+// State returns the editable content of the book.
+func (b *Book) State() *BookState {
+	state := &BookState{
+		Title:           b.Title,
+		Authors:         b.Authors,
+		ISBN:            b.ISBN,
+		PublicationDate: b.PublicationDate.Format("2006-01-02"),
+		Genre:           b.Genre,
+		Description:     b.Description,
+		Publisher:       b.Publisher,
+		Language:        b.Language,
+		Pages:           b.Pages,
+		Format:          b.Format,
+		DurationMinutes: b.DurationMinutes,
+		OriginalYear:    b.OriginalYear,
+		Contributors:    []Credit{},
+		Genres:          []string{},
+	}
+	if state.Authors == nil {
+		state.Authors = []string{}
+	}
+
+	contributors := slices.Clone(b.Contributors)
+	slices.SortStableFunc(contributors, func(x, y Contributor) int {
+		if c := strings.Compare(x.Role, y.Role); c != 0 {
+			return c
+		}
+		return x.Position - y.Position
+	})
+	for _, c := range contributors {
+		if c.Role != RoleAuthor {
+			state.Contributors = append(state.Contributors, Credit{Name: c.Name, Role: c.Role})
+		}
+	}
+
+	for _, genre := range b.Genres {
+		state.Genres = append(state.Genres, genre.Slug)
+	}
+	slices.Sort(state.Genres)
+	return state
+}
+
+// ApplyTo overwrites the book with the state. The genres are left to the
+// caller since they have to be looked up by slug.
+func (s *BookState) ApplyTo(b *Book) error {
+	date, err := time.Parse("2006-01-02", s.PublicationDate)
+	if err != nil {
+		return err
+	}
+
+	b.Title = s.Title
+	b.Authors = slices.Clone(s.Authors)
+	b.ISBN = s.ISBN
+	b.PublicationDate = date
+	b.Genre = s.Genre
+	b.Description = s.Description
+	b.Publisher = s.Publisher
+	b.Language = s.Language
+	b.Pages = s.Pages
+	b.Format = s.Format
+	b.DurationMinutes = s.DurationMinutes
+	b.OriginalYear = s.OriginalYear
+
+	contributors := []Contributor{}
+	for _, name := range s.Authors {
+		contributors = append(contributors, Contributor{Name: name, Role: RoleAuthor})
+	}
+	for _, c := range s.Contributors {
+		contributors = append(contributors, Contributor{Name: c.Name, Role: c.Role})
+	}
+	b.SetContributors(contributors)
+	return nil
+}
+
+// diffStates lists the fields that differ between two states. A nil from
+// reports every field of to.
+func diffStates(from, to *BookState) ([]FieldChange, error) {
+	fromFields, err := stateFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := stateFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	for _, field := range bookStateFields {
+		before, after := fromFields[field], toFields[field]
+		if bytes.Equal(before, after) {
+			continue
+		}
+		if before == nil {
+			before = json.RawMessage("null")
+		}
+		changes = append(changes, FieldChange{Field: field, From: before, To: after})
+	}
+	return changes, nil
+}
+
+func stateFields(state *BookState) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if state == nil {
+		return fields, nil
+	}
+	js, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(js, &fields)
+	return fields, err
+}
+
+// recordRevision stores the state the book is in after an edit inside tx.
+// Edits that change nothing are not recorded unless they are reverts.
+func recordRevision(ctx context.Context, tx *sql.Tx, book *Book, editorID int, revertedFrom *int) error {
+	var previous *BookState
+	var snapshot []byte
+	err := tx.QueryRowContext(ctx, `
+		SELECT snapshot FROM book_revisions
+		WHERE book_id = $1
+		ORDER BY revision DESC
+		LIMIT 1`, book.ID).Scan(&snapshot)
+	switch {
+	case err == nil:
+		previous = &BookState{}
+		err = json.Unmarshal(snapshot, previous)
+		if err != nil {
+			return err
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	state := book.State()
+	changes, err := diffStates(previous, state)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 && revertedFrom == nil {
+		return nil
+	}
+
+	changed := make([]string, len(changes))
+	for i, change := range changes {
+		changed[i] = change.Field
+	}
+	snapshot, err = json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	var editor *int
+	if editorID > 0 {
+		editor = &editorID
+	}
+
+	query := `
+		INSERT INTO book_revisions (book_id, revision, editor_id, snapshot, changed, reverted_from)
+		VALUES ($1, (SELECT coalesce(max(revision), 0) + 1 FROM book_revisions WHERE book_id = $1), $2, $3, $4, $5)`
+	_, err = tx.ExecContext(ctx, query, book.ID, editor, snapshot, pq.Array(changed), revertedFrom)
+	return err
+}
+
+// Revisions lists the revisions of a book, newest first, each with the
+// changes it made to the one before.
+func (m *BookModel) Revisions(bookID int, filters Filters) ([]*BookRevision, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), revision, editor, username, reverted_from, created_at, snapshot, previous
+		FROM (
+			SELECT r.revision, r.editor_id AS editor, coalesce(u.username, '') AS username, r.reverted_from,
+				r.created_at, r.snapshot, LAG(r.snapshot) OVER (ORDER BY r.revision) AS previous
+			FROM book_revisions r
+			LEFT JOIN users u ON u.id = r.editor_id
+			WHERE r.book_id = $1
+		) revisions
+		ORDER BY revision DESC
+		LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, bookID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*BookRevision{}
+
+	for rows.Next() {
+		revision := BookRevision{BookID: int64(bookID)}
+		var snapshot, previous []byte
+		err := rows.Scan(
+			&totalRecords,
+			&revision.Revision,
+			&revision.EditorID,
+			&revision.Editor,
+			&revision.RevertedFrom,
+			&revision.CreatedAt,
+			&snapshot,
+			&previous,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revision.State = &BookState{}
+		err = json.Unmarshal(snapshot, revision.State)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		var before *BookState
+		if previous != nil {
+			before = &BookState{}
+			err = json.Unmarshal(previous, before)
+			if err != nil {
+				return nil, Metadata{}, err
+			}
+		}
+		revision.Changes, err = diffStates(before, revision.State)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return revisions, metadata, nil
+}
+
+// Revision retrieves one revision of a book with the state it recorded.
+func (m *BookModel) Revision(bookID, revision int) (*BookRevision, error) {
+	query := `
+		SELECT revision, editor_id, reverted_from, created_at, snapshot
+		FROM book_revisions
+		WHERE book_id = $1 AND revision = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	r := BookRevision{BookID: int64(bookID)}
+	var snapshot []byte
+	err := m.DB.QueryRowContext(ctx, query, bookID, revision).Scan(&r.Revision, &r.EditorID, &r.RevertedFrom, &r.CreatedAt, &snapshot)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	r.State = &BookState{}
+	err = json.Unmarshal(snapshot, r.State)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
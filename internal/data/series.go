@@ -0,0 +1,340 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// Series is an ordered run of books such as "The Expanse".
+type Series struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	BookCount   int       `json:"book_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	Version     int       `json:"version"`
+}
+
+// SeriesBook is a book at its position in a series.
+type SeriesBook struct {
+	Position float64 `json:"position"`
+	*Book
+}
+
+// BookSeries is a series as shown on a book, e.g. The Expanse #3.
+type BookSeries struct {
+	ID       int64   `json:"id"`
+	Name     string  `json:"name"`
+	Position float64 `json:"position"`
+}
+
+// SeriesModel handles the database interactions for series.
+type SeriesModel struct {
+	DB *sql.DB
+}
+
+func ValidateSeries(v *validator.Validator, series *Series) {
+	v.Check(series.Name != "", "name", "must be provided")
+	v.Check(len(series.Name) <= 255, "name", "must not be more than 255 characters long")
+	v.Check(len(series.Description) <= 5000, "description", "must not be more than 5000 characters long")
+}
+
+// ValidateSeriesPosition checks a position fits the NUMERIC(6,2) column.
+func ValidateSeriesPosition(v *validator.Validator, position float64) {
+	v.Check(position > 0, "position", "must be greater than zero")
+	v.Check(position < 10000, "position", "must be less than 10000")
+	v.Check(math.Abs(position*100-math.Round(position*100)) < 1e-6, "position", "must not have more than two decimal places")
+}
+
+// Insert adds a new series.
+func (m *SeriesModel) Insert(series *Series) error {
+	query := `
+		INSERT INTO series (name, description)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, series.Name, series.Description).Scan(
+		&series.ID, &series.CreatedAt, &series.Version,
+	)
+}
+
+// Get retrieves a single series by ID.
+func (m *SeriesModel) Get(id int64) (*Series, error) {
+	query := `
+		SELECT s.id, s.name, s.description, s.created_at, s.version,
+			(SELECT COUNT(*) FROM book_series bs
+			INNER JOIN books ON books.id = bs.book_id
+			WHERE bs.series_id = s.id AND books.deleted_at IS NULL)
+		FROM series s
+		WHERE s.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var series Series
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&series.ID, &series.Name, &series.Description, &series.CreatedAt, &series.Version, &series.BookCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &series, nil
+}
+
+// GetAll retrieves the series whose name contains name, an empty name
+// matching every series.
+func (m *SeriesModel) GetAll(name string, filters Filters) ([]*Series, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), s.id, s.name, s.description, s.created_at, s.version,
+			(SELECT COUNT(*) FROM book_series bs
+			INNER JOIN books ON books.id = bs.book_id
+			WHERE bs.series_id = s.id AND books.deleted_at IS NULL)
+		FROM series s
+		WHERE ($1 = '' OR s.name ILIKE '%%' || $1 || '%%')
+		ORDER BY s.%s %s, s.id ASC
+		LIMIT $2 OFFSET $3`, filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	all := []*Series{}
+
+	for rows.Next() {
+		var series Series
+		err := rows.Scan(
+			&totalRecords,
+			&series.ID,
+			&series.Name,
+			&series.Description,
+			&series.CreatedAt,
+			&series.Version,
+			&series.BookCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		all = append(all, &series)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return all, metadata, nil
+}
+
+// Update changes a series. It returns ErrEditConfilct when the series has
+// changed since it was read.
+func (m *SeriesModel) Update(series *Series) error {
+	query := `
+		UPDATE series
+		SET name = $1, description = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, series.Name, series.Description, series.ID, series.Version).Scan(&series.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEditConfilct
+	}
+	return err
+}
+
+// Delete removes a series. Its books stay in the catalog.
+func (m *SeriesModel) Delete(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM series WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetBook puts a book into a series at the given position, or moves it
+// there when it is already part of the series. It returns ErrRecordNotFound
+// when the book does not exist.
+func (m *SeriesModel) SetBook(seriesID, bookID int64, position float64) error {
+	query := `
+		INSERT INTO book_series (book_id, series_id, position)
+		SELECT id, $2, $3 FROM books WHERE id = $1 AND deleted_at IS NULL
+		ON CONFLICT (book_id, series_id) DO UPDATE SET position = EXCLUDED.position`
+
+	return m.changeBooks(seriesID, query, bookID, seriesID, position)
+}
+
+// RemoveBook takes a book out of a series.
+func (m *SeriesModel) RemoveBook(seriesID, bookID int64) error {
+	query := `DELETE FROM book_series WHERE series_id = $1 AND book_id = $2`
+
+	return m.changeBooks(seriesID, query, seriesID, bookID)
+}
+
+// changeBooks runs a change to the books of a series and bumps the version
+// of the series, whose book count and book list change with it. It returns
+// ErrRecordNotFound when the change affects no row.
+func (m *SeriesModel) changeBooks(seriesID int64, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE series SET version = version + 1 WHERE id = $1`, seriesID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetBooks returns the books of a series in reading order.
+func (m *SeriesModel) GetBooks(seriesID int64) ([]*SeriesBook, error) {
+	query := `
+		SELECT bs.position, ` + bookColumns + `
+		FROM book_series bs
+		INNER JOIN books ON books.id = bs.book_id
+		WHERE bs.series_id = $1 AND books.deleted_at IS NULL
+		ORDER BY bs.position, books.publication_date, books.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*SeriesBook{}
+	for rows.Next() {
+		entry := SeriesBook{Book: &Book{}}
+		err := rows.Scan(append([]any{&entry.Position}, entry.scanTargets()...)...)
+		if err != nil {
+			return nil, err
+		}
+		entry.setDerivedFields()
+		books = append(books, &entry)
+	}
+
+	return books, rows.Err()
+}
+
+// NextUnread returns the first book of the series, in reading order, that
+// the user has not marked completed on any of their reading lists. Having
+// completed another edition of the same work counts as read. It returns
+// ErrRecordNotFound when the user has read the whole series.
+func (m *SeriesModel) NextUnread(seriesID int64, userID int) (*SeriesBook, error) {
+	query := `
+		SELECT bs.position, ` + bookColumns + `
+		FROM book_series bs
+		INNER JOIN books ON books.id = bs.book_id
+		WHERE bs.series_id = $1 AND books.deleted_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1
+			FROM book_lists bl
+			INNER JOIN lists_names ln ON ln.id = bl.list_name
+			INNER JOIN books rb ON rb.id = bl.book_id
+			WHERE ln.created_by = $2 AND ln.deleted_at IS NULL
+			AND bl.status = 'completed' AND rb.work_id = books.work_id
+		)
+		ORDER BY bs.position, books.publication_date, books.id
+		LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entry := SeriesBook{Book: &Book{}}
+	err := m.DB.QueryRowContext(ctx, query, seriesID, userID).Scan(append([]any{&entry.Position}, entry.scanTargets()...)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	entry.setDerivedFields()
+	return &entry, nil
+}
+
+// getBookSeries returns the series a book belongs to.
+func getBookSeries(db *sql.DB, bookID int) ([]BookSeries, error) {
+	query := `
+		SELECT s.id, s.name, bs.position
+		FROM book_series bs
+		INNER JOIN series s ON s.id = bs.series_id
+		WHERE bs.book_id = $1
+		ORDER BY s.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []BookSeries{}
+	for rows.Next() {
+		var s BookSeries
+		err := rows.Scan(&s.ID, &s.Name, &s.Position)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+
+	return series, rows.Err()
+}
@@ -0,0 +1,260 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateISBN = errors.New("a book with the same isbn exists")
+
+// states of a book submission
+const (
+	SubmissionPending  = "pending"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+var SubmissionStatuses = []string{SubmissionPending, SubmissionApproved, SubmissionRejected}
+
+// BookSubmission is a book sent in by a member without catalog rights. It
+// stays out of the catalog until a moderator approves it.
+type BookSubmission struct {
+	ID          int64      `json:"id"`
+	SubmittedBy int        `json:"submitted_by"`
+	Status      string     `json:"status"`
+	WorkID      int64      `json:"work_id,omitempty"`
+	Book        *BookState `json:"book"`
+	Reason      string     `json:"reason,omitempty"`
+	BookID      *int       `json:"book_id,omitempty"`
+	ModeratedBy *int       `json:"moderated_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ModeratedAt *time.Time `json:"moderated_at,omitempty"`
+	Version     int        `json:"version"`
+}
+
+// SubmissionModel handles the database interactions for book submissions.
+type SubmissionModel struct {
+	DB *sql.DB
+}
+
+// ValidateRejection checks the reason a moderator gave for a rejection.
+func ValidateRejection(v *validator.Validator, reason string) {
+	v.Check(strings.TrimSpace(reason) != "", "reason", "must be provided")
+	v.Check(len(reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
+
+const submissionColumns = `id, submitted_by, status, coalesce(work_id, 0), book, reason, book_id,
+	moderated_by, created_at, moderated_at, version`
+
+func scanSubmission(row interface{ Scan(...any) error }, totals ...any) (*BookSubmission, error) {
+	var sub BookSubmission
+	var book []byte
+	var bookID, moderatedBy sql.NullInt64
+	var moderatedAt sql.NullTime
+
+	targets := append(totals, &sub.ID, &sub.SubmittedBy, &sub.Status, &sub.WorkID, &book, &sub.Reason, &bookID,
+		&moderatedBy, &sub.CreatedAt, &moderatedAt, &sub.Version)
+	err := row.Scan(targets...)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.Book = &BookState{}
+	err = json.Unmarshal(book, sub.Book)
+	if err != nil {
+		return nil, err
+	}
+	if bookID.Valid {
+		id := int(bookID.Int64)
+		sub.BookID = &id
+	}
+	if moderatedBy.Valid {
+		id := int(moderatedBy.Int64)
+		sub.ModeratedBy = &id
+	}
+	if moderatedAt.Valid {
+		sub.ModeratedAt = &moderatedAt.Time
+	}
+	return &sub, nil
+}
+
+// Insert queues a submission for moderation.
+func (m *SubmissionModel) Insert(sub *BookSubmission) error {
+	book, err := json.Marshal(sub.Book)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO book_submissions (submitted_by, work_id, book)
+		VALUES ($1, NULLIF($2, 0), $3)
+		RETURNING id, status, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, sub.SubmittedBy, sub.WorkID, book).Scan(
+		&sub.ID, &sub.Status, &sub.CreatedAt, &sub.Version)
+}
+
+// Get retrieves a submission by ID.
+func (m *SubmissionModel) Get(id int64) (*BookSubmission, error) {
+	query := `
+		SELECT ` + submissionColumns + `
+		FROM book_submissions
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	sub, err := scanSubmission(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return sub, nil
+}
+
+// GetAll lists the submissions in the given state. A submittedBy of 0
+// matches every member.
+func (m *SubmissionModel) GetAll(status string, submittedBy int, filters Filters) ([]*BookSubmission, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), ` + submissionColumns + `
+		FROM book_submissions
+		WHERE status = $1
+		AND ($2 = 0 OR submitted_by = $2)
+		ORDER BY ` + filters.SortColumn() + ` ` + filters.SortDirection() + `, id ASC
+		LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, submittedBy, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	submissions := []*BookSubmission{}
+
+	for rows.Next() {
+		sub, err := scanSubmission(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		submissions = append(submissions, sub)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return submissions, metadata, nil
+}
+
+// Approve adds the book, as the moderator may have edited it, to the catalog
+// and marks the submission approved in one go. The first revision of the
+// book is credited to the member who submitted it. A submission that was
+// moderated or changed in the meantime fails with ErrEditConfilct.
+func (m *SubmissionModel) Approve(sub *BookSubmission, book *Book, moderatorID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertBook(ctx, tx, book, sub.SubmittedBy)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrDuplicateISBN
+		}
+		return err
+	}
+
+	state, err := json.Marshal(book.State())
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE book_submissions
+		SET status = $1, book = $2, work_id = $3, book_id = $4, moderated_by = $5,
+			moderated_at = NOW(), version = version + 1
+		WHERE id = $6 AND version = $7 AND status = $8
+		RETURNING moderated_at, version`
+	args := []any{SubmissionApproved, state, book.WorkID, book.ID, moderatorID, sub.ID, sub.Version, SubmissionPending}
+
+	var moderatedAt time.Time
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&moderatedAt, &sub.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConfilct
+		default:
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	sub.Status = SubmissionApproved
+	sub.Book = book.State()
+	sub.WorkID = book.WorkID
+	sub.BookID = &book.ID
+	sub.ModeratedBy = &moderatorID
+	sub.ModeratedAt = &moderatedAt
+	return nil
+}
+
+// Reject keeps the submission out of the catalog for the given reason.
+// A submission that was moderated or changed in the meantime fails with
+// ErrEditConfilct.
+func (m *SubmissionModel) Reject(sub *BookSubmission, moderatorID int, reason string) error {
+	query := `
+		UPDATE book_submissions
+		SET status = $1, reason = $2, moderated_by = $3, moderated_at = NOW(), version = version + 1
+		WHERE id = $4 AND version = $5 AND status = $6
+		RETURNING moderated_at, version`
+	args := []any{SubmissionRejected, reason, moderatorID, sub.ID, sub.Version, SubmissionPending}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var moderatedAt time.Time
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&moderatedAt, &sub.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConfilct
+		default:
+			return err
+		}
+	}
+
+	sub.Status = SubmissionRejected
+	sub.Reason = reason
+	sub.ModeratedBy = &moderatorID
+	sub.ModeratedAt = &moderatedAt
+	return nil
+}
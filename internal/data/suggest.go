@@ -0,0 +1,94 @@
+package data
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+const (
+	// SuggestMinLength is the shortest prefix suggestions are made for.
+	SuggestMinLength = 2
+	// suggestThreshold is the word similarity a title or name needs to be
+	// suggested. It is low enough for "Tolkein" to find "Tolkien".
+	suggestThreshold = "0.4"
+)
+
+// Suggestion is a book title or author name matching what has been typed so
+// far.
+type Suggestion struct {
+	Type  string  `json:"type"`
+	ID    int64   `json:"id"`
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+}
+
+// ValidateSuggest checks the typed text and the number of suggestions asked
+// for.
+func ValidateSuggest(v *validator.Validator, q string, limit int) {
+	v.Check(utf8.RuneCountInString(q) >= SuggestMinLength, "q", "must be at least 2 characters long")
+	v.Check(len(q) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit >= 1 && limit <= 20, "limit", "must be between 1 and 20")
+}
+
+// Suggest returns the book titles and author names most similar to q,
+// comparing it with the closest matching words so that partial and
+// misspelled input still matches. Both lookups are served by the trigram
+// indexes in nearest-first order.
+func (m *BookModel) Suggest(q string, limit int) ([]*Suggestion, error) {
+	query := `
+		SELECT type, id, text, score FROM (
+			(SELECT 'book' AS type, id, title AS text, word_similarity($1, title) AS score
+			FROM books
+			WHERE deleted_at IS NULL AND $1 <% title
+			ORDER BY $1 <<-> title
+			LIMIT $2)
+			UNION ALL
+			(SELECT 'author', au.id, au.name, word_similarity($1, au.name)
+			FROM authors au
+			WHERE $1 <% au.name AND EXISTS (
+				SELECT 1 FROM book_contributors c
+				INNER JOIN books ON books.id = c.book_id
+				WHERE c.author_id = au.id AND books.deleted_at IS NULL
+			)
+			ORDER BY $1 <<-> au.name
+			LIMIT $2)
+		) suggestions
+		ORDER BY score DESC, length(text), text
+		LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// the default threshold of 0.6 misses single typos in short words
+	_, err = tx.ExecContext(ctx, `SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`, suggestThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, query, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*Suggestion{}
+	for rows.Next() {
+		var s Suggestion
+		err := rows.Scan(&s.Type, &s.ID, &s.Text, &s.Score)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &s)
+	}
+
+	return suggestions, rows.Err()
+}
@@ -0,0 +1,196 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/validator"
+)
+
+// TagCount is a tag with the number of times it has been put on books.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// BookTags are the tags on a book: every member's, counted, and the current
+// user's own.
+type BookTags struct {
+	Tags   []TagCount `json:"tags"`
+	MyTags []string   `json:"my_tags"`
+}
+
+// TagModel handles the database interactions for the tags members put on
+// books.
+type TagModel struct {
+	DB *sql.DB
+}
+
+// NormalizeTag turns a tag as typed into the form it is stored in, e.g.
+// "Beach Read" into "beach-read".
+func NormalizeTag(tag string) string {
+	return Slugify(tag)
+}
+
+func ValidateTag(v *validator.Validator, tag string) {
+	v.Check(tag != "", "tag", "must contain a letter or digit")
+	v.Check(len(tag) <= 50, "tag", "must not be more than 50 characters long")
+}
+
+// Add puts a tag on a book for a user. Adding a tag the book already has
+// does nothing.
+func (m *TagModel) Add(userID, bookID int, tag string) error {
+	query := `
+		INSERT INTO book_tags (user_id, book_id, tag)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, bookID, tag)
+	return err
+}
+
+// Remove takes a user's tag off a book.
+func (m *TagModel) Remove(userID, bookID int, tag string) error {
+	query := `
+		DELETE FROM book_tags
+		WHERE user_id = $1 AND book_id = $2 AND tag = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, bookID, tag)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// ForBook returns the tags on a book, counted by the members who used them,
+// along with the tags userID put on it.
+func (m *TagModel) ForBook(bookID, userID int) (*BookTags, error) {
+	query := `
+		SELECT tag, COUNT(*) AS total, bool_or(user_id = $2)
+		FROM book_tags
+		WHERE book_id = $1
+		GROUP BY tag
+		ORDER BY total DESC, tag ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, bookID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := BookTags{Tags: []TagCount{}, MyTags: []string{}}
+	for rows.Next() {
+		var tag TagCount
+		var mine bool
+		err := rows.Scan(&tag.Tag, &tag.Count, &mine)
+		if err != nil {
+			return nil, err
+		}
+		tags.Tags = append(tags.Tags, tag)
+		if mine {
+			tags.MyTags = append(tags.MyTags, tag.Tag)
+		}
+	}
+
+	return &tags, rows.Err()
+}
+
+// Cloud returns the most used tags with the number of books carrying each.
+// A userID above 0 counts only that user's tags, their personal shelves,
+// otherwise a book counts once however many members tagged it.
+func (m *TagModel) Cloud(userID, limit int) ([]TagCount, error) {
+	query := `
+		SELECT bt.tag, COUNT(DISTINCT bt.book_id) AS total
+		FROM book_tags bt
+		INNER JOIN books ON books.id = bt.book_id
+		WHERE books.deleted_at IS NULL AND ($1 = 0 OR bt.user_id = $1)
+		GROUP BY bt.tag
+		ORDER BY total DESC, bt.tag ASC
+		LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		err := rows.Scan(&tag.Tag, &tag.Count)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// Books lists the books carrying a tag, those tagged by the most members
+// first. A userID above 0 lists only the books that user tagged.
+func (m *TagModel) Books(tag string, userID int, filters Filters) ([]*Book, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), ` + bookColumns + `
+		FROM books
+		INNER JOIN (
+			SELECT book_id, COUNT(*) AS taggers
+			FROM book_tags
+			WHERE tag = $1 AND ($2 = 0 OR user_id = $2)
+			GROUP BY book_id
+		) tagged ON tagged.book_id = books.id
+		WHERE books.deleted_at IS NULL
+		ORDER BY tagged.taggers DESC, books.title ASC, books.id ASC
+		LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, tag, userID, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	books := []*Book{}
+
+	for rows.Next() {
+		var book Book
+		err := rows.Scan(append([]any{&totalRecords}, book.scanTargets()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		book.setDerivedFields()
+		books = append(books, &book)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return books, metadata, nil
+}
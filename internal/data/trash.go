@@ -0,0 +1,265 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrRestoreConflict = errors.New("a live record with the same unique value exists")
+
+// The kinds of record that can sit in the trash.
+const (
+	TrashBook   = "book"
+	TrashList   = "list"
+	TrashReview = "review"
+)
+
+var TrashKinds = []string{TrashBook, TrashList, TrashReview}
+
+// trashTables describes where each kind lives. owner is the user the record
+// belongs to, who may restore it as well as whoever deleted it.
+var trashTables = map[string]struct {
+	table string
+	title string
+	owner string
+}{
+	TrashBook:   {table: "books", title: "title", owner: "NULL::int"},
+	TrashList:   {table: "lists_names", title: "name", owner: "created_by"},
+	TrashReview: {table: "boo_reviews", title: "left(coalesce(review_text, ''), 100)", owner: "user_id"},
+}
+
+// TrashItem is a soft deleted book, reading list or review.
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy *int      `json:"deleted_by"`
+	OwnerID   *int      `json:"-"`
+}
+
+// PurgeResult counts the records removed for good by Purge.
+type PurgeResult struct {
+	Books   int64
+	Lists   int64
+	Reviews int64
+	// CoverKeys are the covers of the purged books, whose files may now be
+	// unused
+	CoverKeys []string
+}
+
+type TrashModel struct {
+	DB *sql.DB
+}
+
+// trashQuery selects every deleted record of the given kinds.
+func trashQuery(kinds []string) string {
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		t := trashTables[kind]
+		parts = append(parts, fmt.Sprintf(`
+			SELECT '%s' AS type, id::bigint AS id, %s AS title, deleted_at, deleted_by, %s AS owner_id
+			FROM %s WHERE deleted_at IS NOT NULL`, kind, t.title, t.owner, t.table))
+	}
+	return strings.Join(parts, "\n\t\t\tUNION ALL")
+}
+
+// GetAll lists the trash. Unless everyone is set, only the records the user
+// deleted or owns are returned. An empty kind matches every kind.
+func (m *TrashModel) GetAll(userID int, everyone bool, kind string, filters Filters) ([]*TrashItem, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), type, id, title, deleted_at, deleted_by, owner_id
+		FROM (%s
+		) trash
+		WHERE ($1 OR deleted_by = $2 OR owner_id = $2)
+		AND ($3 = '' OR type = $3)
+		ORDER BY %s %s, type ASC, id ASC
+		LIMIT $4 OFFSET $5`, trashQuery(TrashKinds), filters.SortColumn(), filters.SortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, everyone, userID, kind, filters.Limit(), filters.Offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*TrashItem{}
+
+	for rows.Next() {
+		var item TrashItem
+		err := rows.Scan(
+			&totalRecords,
+			&item.Type,
+			&item.ID,
+			&item.Title,
+			&item.DeletedAt,
+			&item.DeletedBy,
+			&item.OwnerID,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := CalculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+// Get retrieves a single record from the trash.
+func (m *TrashModel) Get(kind string, id int64) (*TrashItem, error) {
+	query := fmt.Sprintf(`
+		SELECT type, id, title, deleted_at, deleted_by, owner_id
+		FROM (%s
+		) trash
+		WHERE id = $1`, trashQuery([]string{kind}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var item TrashItem
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&item.Type,
+		&item.ID,
+		&item.Title,
+		&item.DeletedAt,
+		&item.DeletedBy,
+		&item.OwnerID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &item, nil
+}
+
+// Restore takes a record out of the trash. Restoring a book fails with
+// ErrRestoreConflict when another book has taken its ISBN in the meantime.
+// A restored review counts towards the ratings of its book again.
+func (m *TrashModel) Restore(kind string, id int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = NULL, deleted_by = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL`, trashTables[kind].table)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrRestoreConflict
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if kind == TrashReview {
+		var bookID int64
+		err = tx.QueryRowContext(ctx, `SELECT book_id FROM boo_reviews WHERE id = $1`, id).Scan(&bookID)
+		if err != nil {
+			return err
+		}
+		err = refreshRatings(ctx, tx, bookID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Purge permanently deletes everything that was moved to the trash before
+// the given time. Purged books take their reviews and list entries with them.
+func (m *TrashModel) Purge(before time.Time) (*PurgeResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var result PurgeResult
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM boo_reviews WHERE deleted_at < $1`, before)
+	if err != nil {
+		return nil, err
+	}
+	result.Reviews, err = res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = tx.ExecContext(ctx, `DELETE FROM lists_names WHERE deleted_at < $1`, before)
+	if err != nil {
+		return nil, err
+	}
+	result.Lists, err = res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM books WHERE deleted_at < $1 RETURNING work_id, cover_key`, before)
+	if err != nil {
+		return nil, err
+	}
+	works := []int64{}
+	for rows.Next() {
+		var workID int64
+		var coverKey string
+		if err := rows.Scan(&workID, &coverKey); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.Books++
+		works = append(works, workID)
+		if coverKey != "" {
+			result.CoverKeys = append(result.CoverKeys, coverKey)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = deleteEmptyWorks(ctx, tx, works)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, tx.Commit()
+}
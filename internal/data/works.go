@@ -0,0 +1,237 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrUnknownWork = errors.New("unknown work")
+
+// ReviewStats summarises the reviews left on an edition or on every edition
+// of a work.
+type ReviewStats struct {
+	AverageRating float64 `json:"average_rating"`
+	ReviewCount   int     `json:"review_count"`
+}
+
+// Work groups the editions of the same book, e.g. the hardcover, paperback
+// and translations of one novel.
+type Work struct {
+	ID           int64       `json:"id"`
+	Title        string      `json:"title"`
+	EditionCount int         `json:"edition_count"`
+	Reviews      ReviewStats `json:"reviews"`
+	CreatedAt    time.Time   `json:"created_at"`
+	Version      int         `json:"version"`
+}
+
+// Edition is a book listed under its work, with its own review aggregates.
+type Edition struct {
+	*Book
+	Reviews ReviewStats `json:"reviews"`
+}
+
+type WorkModel struct {
+	DB *sql.DB
+}
+
+// Get retrieves a work with its edition count and the review aggregates
+// across all of its editions.
+func (m *WorkModel) Get(id int64) (*Work, error) {
+	query := `
+		SELECT w.id, w.title, w.created_at, w.version,
+			(SELECT COUNT(*) FROM books WHERE work_id = w.id AND deleted_at IS NULL),
+			COALESCE(AVG(r.rating), 0), COUNT(r.id)
+		FROM works w
+		LEFT JOIN books b ON b.work_id = w.id AND b.deleted_at IS NULL
+		LEFT JOIN boo_reviews r ON r.book_id = b.id AND r.deleted_at IS NULL
+		WHERE w.id = $1
+		GROUP BY w.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var work Work
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&work.ID,
+		&work.Title,
+		&work.CreatedAt,
+		&work.Version,
+		&work.EditionCount,
+		&work.Reviews.AverageRating,
+		&work.Reviews.ReviewCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &work, nil
+}
+
+// GetEditions retrieves the editions of a work, each with the aggregates of
+// its own reviews.
+func (m *WorkModel) GetEditions(workID int64) ([]*Edition, error) {
+	query := `
+		SELECT ` + bookColumns + `,
+			COALESCE((SELECT AVG(rating) FROM boo_reviews WHERE book_id = books.id AND deleted_at IS NULL), 0),
+			(SELECT COUNT(*) FROM boo_reviews WHERE book_id = books.id AND deleted_at IS NULL)
+		FROM books
+		WHERE books.work_id = $1 AND books.deleted_at IS NULL
+		ORDER BY books.publication_date ASC, books.id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, workID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	editions := []*Edition{}
+	for rows.Next() {
+		edition := Edition{Book: &Book{}}
+		dest := append(edition.scanTargets(), &edition.Reviews.AverageRating, &edition.Reviews.ReviewCount)
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		edition.setDerivedFields()
+		editions = append(editions, &edition)
+	}
+
+	return editions, rows.Err()
+}
+
+// MergeEditions moves the given books under the work, so they are listed as
+// editions of it. Works left without any edition are removed. It returns
+// ErrRecordNotFound if the work or any of the books does not exist.
+func (m *WorkModel) MergeEditions(workID int64, bookIDs []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM works WHERE id = $1)`, workID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		WITH moved AS (
+			SELECT id, work_id FROM books WHERE id = ANY($2) AND deleted_at IS NULL FOR UPDATE
+		)
+		UPDATE books SET work_id = $1, version = books.version + 1
+		FROM moved
+		WHERE books.id = moved.id
+		RETURNING moved.work_id`
+
+	rows, err := tx.QueryContext(ctx, query, workID, pq.Array(bookIDs))
+	if err != nil {
+		return err
+	}
+	previous := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		previous = append(previous, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(previous) != len(bookIDs) {
+		return ErrRecordNotFound
+	}
+
+	err = deleteEmptyWorks(ctx, tx, previous)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SplitEdition moves a book out of its work into a new work of its own and
+// returns the new work.
+func (m *WorkModel) SplitEdition(bookID int64) (*Work, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var title string
+	var previous int64
+	err = tx.QueryRowContext(ctx, `SELECT title, work_id FROM books WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, bookID).Scan(&title, &previous)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	work := Work{Title: title, EditionCount: 1}
+	query := `
+		INSERT INTO works (title)
+		VALUES ($1)
+		RETURNING id, created_at, version`
+	err = tx.QueryRowContext(ctx, query, title).Scan(&work.ID, &work.CreatedAt, &work.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE books SET work_id = $1, version = version + 1 WHERE id = $2`, work.ID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = deleteEmptyWorks(ctx, tx, []int64{previous})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(AVG(rating), 0), COUNT(*) FROM boo_reviews WHERE book_id = $1 AND deleted_at IS NULL`, bookID).
+		Scan(&work.Reviews.AverageRating, &work.Reviews.ReviewCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &work, tx.Commit()
+}
+
+// deleteEmptyWorks removes those of the given works that no longer have any
+// editions.
+func deleteEmptyWorks(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	query := `
+		DELETE FROM works w
+		WHERE w.id = ANY($1)
+		AND NOT EXISTS (SELECT 1 FROM books WHERE work_id = w.id)`
+
+	_, err := tx.ExecContext(ctx, query, pq.Array(ids))
+	return err
+}
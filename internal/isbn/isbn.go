@@ -0,0 +1,166 @@
+// Package isbn parses, checks and formats International Standard Book Numbers.
+// Every ISBN is stored in its canonical form: thirteen digits with no
+// separators. ISBN-10s are converted to ISBN-13 by adding the 978 prefix.
+package isbn
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCharacter = errors.New("isbn: invalid character")
+	ErrInvalidLength    = errors.New("isbn: must have 10 or 13 digits")
+	ErrInvalidChecksum  = errors.New("isbn: invalid check digit")
+)
+
+// Parse accepts an ISBN-10 or ISBN-13, with or without hyphens and spaces,
+// verifies its check digit and returns the canonical ISBN-13.
+func Parse(value string) (string, error) {
+	digits := make([]byte, 0, 13)
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = append(digits, byte(r))
+		case r == 'X' || r == 'x':
+			digits = append(digits, 'X')
+		case r == '-' || r == ' ':
+			continue
+		default:
+			return "", ErrInvalidCharacter
+		}
+	}
+
+	// X is only valid as the check digit of an ISBN-10
+	if i := strings.IndexByte(string(digits), 'X'); i != -1 && !(len(digits) == 10 && i == 9) {
+		return "", ErrInvalidCharacter
+	}
+
+	switch len(digits) {
+	case 10:
+		if checkDigit10(string(digits[:9])) != digits[9] {
+			return "", ErrInvalidChecksum
+		}
+		body := "978" + string(digits[:9])
+		return body + string(checkDigit13(body)), nil
+	case 13:
+		if checkDigit13(string(digits[:12])) != digits[12] {
+			return "", ErrInvalidChecksum
+		}
+		return string(digits), nil
+	default:
+		return "", ErrInvalidLength
+	}
+}
+
+// checkDigit10 computes the ISBN-10 check digit for the first nine digits.
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// checkDigit13 computes the ISBN-13 check digit for the first twelve digits.
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(body[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// rangeRule gives the length of the next element of an ISBN when the seven
+// digits that follow fall between from and to.
+type rangeRule struct {
+	from, to int
+	length   int
+}
+
+// Registration groups for the 978 and 979 prefixes.
+var groupRules = map[string][]rangeRule{
+	"978": {
+		{0, 5999999, 1},
+		{6000000, 6499999, 3},
+		{6500000, 6599999, 2},
+		{6600000, 6999999, 3},
+		{7000000, 7999999, 1},
+		{8000000, 9499999, 2},
+		{9500000, 9899999, 3},
+		{9900000, 9989999, 4},
+		{9990000, 9999999, 5},
+	},
+	"979": {
+		{1000000, 1299999, 2},
+		{8000000, 8999999, 1},
+	},
+}
+
+// Registrant ranges are only kept for the English language groups, which
+// cover most of the catalog.
+var registrantRules = map[string][]rangeRule{
+	"978-0": {
+		{0, 1999999, 2},
+		{2000000, 6999999, 3},
+		{7000000, 8499999, 4},
+		{8500000, 8999999, 5},
+		{9000000, 9499999, 6},
+		{9500000, 9999999, 7},
+	},
+	"978-1": {
+		{0, 999999, 2},
+		{1000000, 3999999, 3},
+		{4000000, 5499999, 4},
+		{5500000, 8697999, 5},
+		{8698000, 9989999, 6},
+		{9990000, 9999999, 7},
+	},
+}
+
+func lookup(rules []rangeRule, digits string) int {
+	n, err := strconv.Atoi((digits + "0000000")[:7])
+	if err != nil {
+		return 0
+	}
+	for _, rule := range rules {
+		if n >= rule.from && n <= rule.to {
+			return rule.length
+		}
+	}
+	return 0
+}
+
+// Hyphenate returns the display form of an ISBN, for example
+// 978-0-306-40615-7. When the registrant ranges of a group are not known the
+// registrant and publication elements are left together. Values that do not
+// parse are returned unchanged.
+func Hyphenate(value string) string {
+	canonical, err := Parse(value)
+	if err != nil {
+		return value
+	}
+
+	prefix, rest, check := canonical[:3], canonical[3:12], canonical[12:]
+	groupLength := lookup(groupRules[prefix], rest)
+	if groupLength == 0 {
+		return strings.Join([]string{prefix, rest, check}, "-")
+	}
+
+	group, rest := rest[:groupLength], rest[groupLength:]
+	registrantLength := lookup(registrantRules[prefix+"-"+group], rest)
+	if registrantLength == 0 || registrantLength >= len(rest) {
+		return strings.Join([]string{prefix, group, rest, check}, "-")
+	}
+
+	return strings.Join([]string{prefix, group, rest[:registrantLength], rest[registrantLength:], check}, "-")
+}
@@ -0,0 +1,110 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		err   error
+	}{
+		{"isbn-13", "9780306406157", "9780306406157", nil},
+		{"isbn-13 with hyphens", "978-0-306-40615-7", "9780306406157", nil},
+		{"isbn-13 with spaces", "978 0 306 40615 7", "9780306406157", nil},
+		{"isbn-10 to isbn-13", "0306406152", "9780306406157", nil},
+		{"isbn-10 with hyphens", "0-306-40615-2", "9780306406157", nil},
+		{"isbn-10 with X check digit", "080442957X", "9780804429573", nil},
+		{"isbn-10 with lower case x", "0-8044-2957-x", "9780804429573", nil},
+		{"isbn-13 with check digit 0", "9780200000000", "9780200000000", nil},
+		{"isbn-13 wrong check digit", "9780306406158", "", ErrInvalidChecksum},
+		{"isbn-10 wrong check digit", "0306406153", "", ErrInvalidChecksum},
+		{"isbn-10 should end in X", "0804429570", "", ErrInvalidChecksum},
+		{"X not at the end", "08044295X7", "", ErrInvalidCharacter},
+		{"X in an isbn-13", "978080442957X", "", ErrInvalidCharacter},
+		{"letter", "978030640615A", "", ErrInvalidCharacter},
+		{"dot separator", "978.0.306.40615.7", "", ErrInvalidCharacter},
+		{"too short", "030640615", "", ErrInvalidLength},
+		{"too long", "97803064061570", "", ErrInvalidLength},
+		{"between lengths", "97803064061", "", ErrInvalidLength},
+		{"empty", "", "", ErrInvalidLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.value, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDigits(t *testing.T) {
+	tests10 := []struct {
+		body string
+		want byte
+	}{
+		{"030640615", '2'},
+		{"080442957", 'X'},
+		{"000000001", '9'},
+		{"000000000", '0'},
+	}
+	for _, tt := range tests10 {
+		if got := checkDigit10(tt.body); got != tt.want {
+			t.Errorf("checkDigit10(%q) = %c, want %c", tt.body, got, tt.want)
+		}
+	}
+
+	tests13 := []struct {
+		body string
+		want byte
+	}{
+		{"978030640615", '7'},
+		{"978080442957", '3'},
+		{"978020000000", '0'},
+		{"979100000000", '8'},
+	}
+	for _, tt := range tests13 {
+		if got := checkDigit13(tt.body); got != tt.want {
+			t.Errorf("checkDigit13(%q) = %c, want %c", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestHyphenate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"known registrant", "9780306406157", "978-0-306-40615-7"},
+		{"isbn-10 input", "0-8044-2957-X", "978-0-8044-2957-3"},
+		{"last two digit registrant in group 0", "9780199999996", "978-0-19-999999-6"},
+		{"first three digit registrant in group 0", "9780200000000", "978-0-200-00000-0"},
+		{"last five digit registrant in group 1", "9781869799991", "978-1-86979-999-1"},
+		{"first six digit registrant in group 1", "9781869800000", "978-1-869800-00-0"},
+		{"unknown registrant ranges", "9783999999999", "978-3-99999999-9"},
+		{"two digit group", "9786500000009", "978-65-0000000-9"},
+		{"three digit group", "9786000000004", "978-600-000000-4"},
+		{"five digit group", "9789999999991", "978-99999-9999-1"},
+		{"979 group", "9791000000008", "979-10-0000000-8"},
+		{"unassigned 979 group", "9790000000001", "979-000000000-1"},
+		{"invalid value", "9780306406158", "9780306406158"},
+		{"not an isbn", "n/a", "n/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hyphenate(tt.value); got != tt.want {
+				t.Errorf("Hyphenate(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+// Package openlibrary looks up book metadata in a local copy of the Open
+// Library data dumps (https://openlibrary.org/developers/dumps). The editions
+// dump, and optionally the authors dump, are indexed on disk once so that
+// lookups only read a handful of bytes.
+package openlibrary
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RayMC17/bookclub-api/internal/data"
+	"github.com/RayMC17/bookclub-api/internal/isbn"
+)
+
+const authorKeyWidth = 32
+
+// Dump is a data.MetadataProvider backed by the indexed dumps.
+type Dump struct {
+	editions *index
+	authors  *index
+}
+
+// edition is the part of an Open Library edition that we keep.
+type edition struct {
+	Title       string          `json:"title"`
+	Subtitle    string          `json:"subtitle,omitempty"`
+	ISBN10      []string        `json:"isbn_10,omitempty"`
+	ISBN13      []string        `json:"isbn_13,omitempty"`
+	PublishDate string          `json:"publish_date,omitempty"`
+	ByStatement string          `json:"by_statement,omitempty"`
+	Subjects    []string        `json:"subjects,omitempty"`
+	Description json.RawMessage `json:"description,omitempty"`
+	Authors     []struct {
+		Key string `json:"key"`
+	} `json:"authors,omitempty"`
+}
+
+type author struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// Load opens the index in dir, building it first when it is missing or older
+// than the editions dump. authorsPath may be empty, in which case author
+// names are taken from the edition's by-statement.
+func Load(editionsPath, authorsPath, dir string) (*Dump, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	editionsBase := filepath.Join(dir, "editions")
+	if stale(editionsPath, editionsBase) {
+		err = buildIndex(editionsBase, 13, editionsPath, extractEdition)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	authorsBase := filepath.Join(dir, "authors")
+	if authorsPath != "" && stale(authorsPath, authorsBase) {
+		err = buildIndex(authorsBase, authorKeyWidth, authorsPath, extractAuthor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dump := &Dump{}
+	dump.editions, err = openIndex(editionsBase, 13)
+	if err != nil {
+		return nil, err
+	}
+	if authorsPath != "" {
+		dump.authors, err = openIndex(authorsBase, authorKeyWidth)
+		if err != nil {
+			dump.editions.Close()
+			return nil, err
+		}
+	}
+
+	return dump, nil
+}
+
+// Provider is a data.MetadataProvider whose index is loaded in the
+// background, since building it from a full dump takes a long time. Until
+// it is ready, or when loading failed, lookups fail with
+// data.ErrMetadataUnavailable.
+type Provider struct {
+	mu     sync.RWMutex
+	dump   *Dump
+	closed bool
+}
+
+// LoadInBackground runs Load in its own goroutine and returns at once. done
+// is called with the outcome when loading has finished.
+func LoadInBackground(editionsPath, authorsPath, dir string, done func(error)) *Provider {
+	p := &Provider{}
+	go func() {
+		dump, err := Load(editionsPath, authorsPath, dir)
+		if err == nil {
+			p.mu.Lock()
+			if p.closed {
+				dump.Close()
+			} else {
+				p.dump = dump
+			}
+			p.mu.Unlock()
+		}
+		done(err)
+	}()
+	return p
+}
+
+// LookupISBN implements data.MetadataProvider.
+func (p *Provider) LookupISBN(isbn13 string) (*data.BookMetadata, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.dump == nil {
+		return nil, data.ErrMetadataUnavailable
+	}
+	return p.dump.LookupISBN(isbn13)
+}
+
+// Close releases the index, or drops it as soon as it is loaded.
+func (p *Provider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	if p.dump == nil {
+		return nil
+	}
+	err := p.dump.Close()
+	p.dump = nil
+	return err
+}
+
+// stale reports whether the index at base needs to be rebuilt from dumpPath.
+func stale(dumpPath, base string) bool {
+	idx, err := os.Stat(base + ".idx")
+	if err != nil {
+		return true
+	}
+	dump, err := os.Stat(dumpPath)
+	if err != nil {
+		return false
+	}
+	return dump.ModTime().After(idx.ModTime())
+}
+
+// Close releases the index files.
+func (d *Dump) Close() error {
+	err := d.editions.Close()
+	if d.authors != nil {
+		if authorsErr := d.authors.Close(); err == nil {
+			err = authorsErr
+		}
+	}
+	return err
+}
+
+// LookupISBN implements data.MetadataProvider.
+func (d *Dump) LookupISBN(isbn13 string) (*data.BookMetadata, error) {
+	raw, err := d.editions.lookup(isbn13)
+	if err != nil {
+		if errors.Is(err, errKeyNotFound) {
+			return nil, data.ErrMetadataNotFound
+		}
+		return nil, err
+	}
+
+	var ed edition
+	err = json.Unmarshal(raw, &ed)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &data.BookMetadata{
+		Title:           ed.Title,
+		PublicationDate: parsePublishDate(ed.PublishDate),
+		Description:     textValue(ed.Description),
+	}
+	if ed.Subtitle != "" {
+		meta.Title += ": " + ed.Subtitle
+	}
+	if len(ed.Subjects) > 0 {
+		meta.Genre = ed.Subjects[0]
+	}
+
+	if d.authors != nil {
+		for _, a := range ed.Authors {
+			raw, err := d.authors.lookup(a.Key)
+			if err != nil {
+				continue
+			}
+			var au author
+			if json.Unmarshal(raw, &au) == nil && au.Name != "" {
+				meta.Authors = append(meta.Authors, au.Name)
+			}
+		}
+	}
+	if len(meta.Authors) == 0 && ed.ByStatement != "" {
+		meta.Authors = []string{strings.TrimSuffix(strings.TrimPrefix(ed.ByStatement, "by "), ".")}
+	}
+
+	return meta, nil
+}
+
+// extractEdition keys an edition by every ISBN it carries, all in ISBN-13
+// form.
+func extractEdition(raw []byte) ([]string, []byte, error) {
+	var ed edition
+	err := json.Unmarshal(raw, &ed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := []string{}
+	for _, value := range append(ed.ISBN13, ed.ISBN10...) {
+		canonical, err := isbn.Parse(value)
+		if err == nil {
+			keys = append(keys, canonical)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+
+	record, err := json.Marshal(ed)
+	return keys, record, err
+}
+
+func extractAuthor(raw []byte) ([]string, []byte, error) {
+	var au author
+	err := json.Unmarshal(raw, &au)
+	if err != nil || au.Key == "" {
+		return nil, nil, err
+	}
+
+	record, err := json.Marshal(au)
+	return []string{au.Key}, record, err
+}
+
+// textValue reads a field that is either a plain string or a
+// {"type": "/type/text", "value": "..."} object.
+func textValue(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	var text struct {
+		Value string `json:"value"`
+	}
+	json.Unmarshal(raw, &text)
+	return text.Value
+}
+
+var yearRX = regexp.MustCompile(`(?:^|[^0-9])(1[0-9]{3}|20[0-9]{2})(?:[^0-9]|$)`)
+
+// parsePublishDate understands the free-text dates used by Open Library, such
+// as "March 4, 1999", "1999-03-04" or "1999". When only a year can be found
+// the date is set to the first of January of that year.
+func parsePublishDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02", "January 2, 2006", "Jan 2, 2006", "2 January 2006", "January 2006", "Jan 2006", "2006"} {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return date
+		}
+	}
+
+	match := yearRX.FindStringSubmatch(value)
+	if match == nil {
+		return time.Time{}
+	}
+	date, _ := time.Parse("2006", match[1])
+	return date
+}
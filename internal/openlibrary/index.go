@@ -0,0 +1,374 @@
+package openlibrary
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// An index is a pair of files on disk. The .data file holds one JSON record
+// per line and the .idx file holds fixed-width entries, sorted by key, of the
+// key (zero padded) followed by the big-endian offset of its record.
+type index struct {
+	data     *os.File
+	idx      *os.File
+	keyWidth int
+	count    int64
+}
+
+type indexEntry struct {
+	key    string
+	offset int64
+}
+
+var errKeyNotFound = errors.New("openlibrary: key not found")
+
+// maximum size of a single record or dump line
+const maxLineBytes = 16 << 20
+
+func openIndex(base string, keyWidth int) (*index, error) {
+	data, err := os.Open(base + ".data")
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := os.Open(base + ".idx")
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+
+	info, err := idx.Stat()
+	if err != nil {
+		data.Close()
+		idx.Close()
+		return nil, err
+	}
+
+	return &index{
+		data:     data,
+		idx:      idx,
+		keyWidth: keyWidth,
+		count:    info.Size() / int64(keyWidth+8),
+	}, nil
+}
+
+func (ix *index) Close() error {
+	err := ix.data.Close()
+	if idxErr := ix.idx.Close(); err == nil {
+		err = idxErr
+	}
+	return err
+}
+
+// lookup binary searches the index for key and returns its record.
+func (ix *index) lookup(key string) ([]byte, error) {
+	if len(key) > ix.keyWidth {
+		return nil, errKeyNotFound
+	}
+	want := padKey(key, ix.keyWidth)
+
+	entrySize := int64(ix.keyWidth + 8)
+	entry := make([]byte, entrySize)
+
+	low, high := int64(0), ix.count-1
+	for low <= high {
+		mid := (low + high) / 2
+		_, err := ix.idx.ReadAt(entry, mid*entrySize)
+		if err != nil {
+			return nil, err
+		}
+
+		switch bytes.Compare(entry[:ix.keyWidth], want) {
+		case -1:
+			low = mid + 1
+		case 1:
+			high = mid - 1
+		default:
+			offset := int64(binary.BigEndian.Uint64(entry[ix.keyWidth:]))
+			return ix.record(offset)
+		}
+	}
+
+	return nil, errKeyNotFound
+}
+
+func (ix *index) record(offset int64) ([]byte, error) {
+	reader := bufio.NewReader(io.NewSectionReader(ix.data, offset, maxLineBytes))
+	line, err := reader.ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return bytes.TrimSuffix(line, []byte("\n")), nil
+}
+
+func padKey(key string, width int) []byte {
+	padded := make([]byte, width)
+	copy(padded, key)
+	return padded
+}
+
+// extractFunc turns the JSON of one dump line into the keys it should be
+// found under and the record to store. Lines that yield no keys are dropped.
+type extractFunc func(raw []byte) (keys []string, record []byte, err error)
+
+// entries are sorted in runs of this many, spilled to disk and merged
+// afterwards, so a build needs the same memory whatever the size of the dump
+const runEntries = 1 << 20
+
+// buildIndex reads a dump and writes the .data and .idx files for base. The
+// files are written under temporary names and renamed once complete so a
+// failed build never leaves a half-written index behind.
+func buildIndex(base string, keyWidth int, dumpPath string, extract extractFunc) error {
+	dump, err := openDump(dumpPath)
+	if err != nil {
+		return err
+	}
+	defer dump.Close()
+
+	dataFile, err := os.Create(base + ".data.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dataFile.Name())
+	defer dataFile.Close()
+
+	runs := []*os.File{}
+	defer func() {
+		for _, run := range runs {
+			run.Close()
+			os.Remove(run.Name())
+		}
+	}()
+
+	entries := make([]indexEntry, 0, runEntries)
+	flush := func() error {
+		if len(entries) == 0 {
+			return nil
+		}
+		run, err := writeRun(filepath.Dir(base), entries, keyWidth)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+		entries = entries[:0]
+		return nil
+	}
+
+	writer := bufio.NewWriter(dataFile)
+	var offset int64
+
+	scanner := bufio.NewScanner(dump)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for scanner.Scan() {
+		raw := scanner.Bytes()
+		// the official dumps are tab separated with the JSON in the last
+		// column, plain JSON lines are accepted as well
+		if i := bytes.LastIndexByte(raw, '\t'); i != -1 {
+			raw = raw[i+1:]
+		}
+
+		keys, record, err := extract(raw)
+		if err != nil || len(keys) == 0 {
+			continue
+		}
+
+		for _, key := range keys {
+			if len(key) <= keyWidth {
+				entries = append(entries, indexEntry{key: key, offset: offset})
+			}
+		}
+		if len(entries) >= runEntries {
+			err = flush()
+			if err != nil {
+				return err
+			}
+		}
+
+		n, err := writer.Write(append(record, '\n'))
+		if err != nil {
+			return err
+		}
+		offset += int64(n)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	idxFile, err := os.Create(base + ".idx.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(idxFile.Name())
+	defer idxFile.Close()
+
+	writer = bufio.NewWriter(idxFile)
+	err = mergeRuns(writer, runs, keyWidth)
+	if err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	err = os.Rename(dataFile.Name(), base+".data")
+	if err != nil {
+		return err
+	}
+	return os.Rename(idxFile.Name(), base+".idx")
+}
+
+// writeRun sorts entries and writes them to a temporary file in dir, in
+// the fixed-width form of the .idx file.
+func writeRun(dir string, entries []indexEntry, keyWidth int) (*os.File, error) {
+	// entries come in offset order, which a stable sort keeps for equal keys
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	run, err := os.CreateTemp(dir, "run-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	writer := bufio.NewWriter(run)
+	entry := make([]byte, keyWidth+8)
+	for _, e := range entries {
+		copy(entry, padKey(e.key, keyWidth))
+		binary.BigEndian.PutUint64(entry[keyWidth:], uint64(e.offset))
+		_, err := writer.Write(entry)
+		if err != nil {
+			run.Close()
+			os.Remove(run.Name())
+			return nil, err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		run.Close()
+		os.Remove(run.Name())
+		return nil, err
+	}
+	return run, nil
+}
+
+// runReader walks one sorted run during the merge.
+type runReader struct {
+	reader *bufio.Reader
+	entry  []byte
+}
+
+// next reads the following entry of the run, reporting false at its end.
+func (rr *runReader) next() (bool, error) {
+	_, err := io.ReadFull(rr.reader, rr.entry)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// runHeap orders the runs by their current entry. Entries compare by the
+// padded key and then the big-endian offset, so byte order is enough.
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return bytes.Compare(h[i].entry, h[j].entry) < 0 }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	rr := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return rr
+}
+
+// mergeRuns merges the sorted runs into w. Of the entries for one key only
+// the one with the lowest offset, the first record seen, is kept.
+func mergeRuns(w io.Writer, runs []*os.File, keyWidth int) error {
+	h := runHeap{}
+	for _, run := range runs {
+		_, err := run.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		rr := &runReader{reader: bufio.NewReader(run), entry: make([]byte, keyWidth+8)}
+		ok, err := rr.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h = append(h, rr)
+		}
+	}
+	heap.Init(&h)
+
+	last := make([]byte, keyWidth)
+	written := false
+	for h.Len() > 0 {
+		rr := h[0]
+		if !written || !bytes.Equal(last, rr.entry[:keyWidth]) {
+			_, err := w.Write(rr.entry)
+			if err != nil {
+				return err
+			}
+			copy(last, rr.entry[:keyWidth])
+			written = true
+		}
+
+		ok, err := rr.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
+
+// gzipFile closes both the gzip reader and the file underneath it.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// openDump opens a dump file, decompressing it when it is gzipped.
+func openDump(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		file.Seek(0, io.SeekStart)
+		return file, nil
+	}
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipFile{Reader: gz, file: file}, nil
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS book_imports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'completed', 'failed')) DEFAULT 'running',
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    on_duplicate VARCHAR(10) NOT NULL CHECK (on_duplicate IN ('skip', 'update')) DEFAULT 'skip',
+    total_rows INT NOT NULL DEFAULT 0,
+    report JSONB,
+    error TEXT,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP(0) WITH TIME ZONE
+);
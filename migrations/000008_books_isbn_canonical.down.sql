@@ -0,0 +1 @@
+-- the original formatting of each ISBN is not kept, so there is nothing to undo
@@ -0,0 +1,48 @@
+-- ISBNs are stored as thirteen digits with no separators
+CREATE OR REPLACE FUNCTION isbn13_from_isbn10(isbn10 TEXT) RETURNS TEXT AS $$
+DECLARE
+    body TEXT := '978' || substr(isbn10, 1, 9);
+    total INT := 0;
+BEGIN
+    FOR i IN 1..12 LOOP
+        total := total + substr(body, i, 1)::INT * CASE WHEN i % 2 = 0 THEN 3 ELSE 1 END;
+    END LOOP;
+    RETURN body || ((10 - total % 10) % 10)::TEXT;
+END
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Two books whose ISBNs only differ in formatting, or an ISBN-10 and the
+-- matching ISBN-13, would end up with the same value and break the unique
+-- constraint halfway through. List them all so they can be merged first.
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    WITH stripped AS (
+        SELECT id, isbn, regexp_replace(isbn, '[-[:space:]]', '', 'g') AS digits
+        FROM books
+    ), canonical AS (
+        SELECT id, isbn,
+            CASE WHEN digits ~ '^[0-9]{9}[0-9Xx]$' THEN isbn13_from_isbn10(digits) ELSE digits END AS isbn13
+        FROM stripped
+    )
+    SELECT string_agg(format('%s (books %s)', isbn13, ids), '; ')
+    INTO collisions
+    FROM (
+        SELECT isbn13, string_agg(format('%s: %s', id, isbn), ', ' ORDER BY id) AS ids
+        FROM canonical
+        GROUP BY isbn13
+        HAVING COUNT(*) > 1
+    ) duplicates;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'books share the same canonical isbn: %', collisions
+            USING HINT = 'merge or correct these books, then run the migration again';
+    END IF;
+END
+$$;
+
+UPDATE books SET isbn = regexp_replace(isbn, '[-[:space:]]', '', 'g') WHERE isbn ~ '[-[:space:]]';
+UPDATE books SET isbn = isbn13_from_isbn10(isbn) WHERE isbn ~ '^[0-9]{9}[0-9Xx]$';
+
+DROP FUNCTION isbn13_from_isbn10(TEXT);
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS authors (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    -- lower case letters and digits only, so "J.R.R. Tolkien" and
+    -- "J. R. R. Tolkien" are the same person
+    normalized_name VARCHAR(255) UNIQUE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS book_contributors (
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    author_id BIGINT NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('author', 'translator', 'illustrator', 'editor')) DEFAULT 'author',
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS book_contributors_author_id_idx ON book_contributors (author_id);
+
+-- move the existing author arrays over
+INSERT INTO authors (name, normalized_name)
+SELECT DISTINCT ON (normalized_name) name, normalized_name
+FROM (
+    SELECT trim(a.name) AS name, lower(regexp_replace(a.name, '[^[:alnum:]]+', '', 'g')) AS normalized_name
+    FROM books CROSS JOIN LATERAL unnest(books.authors) AS a(name)
+) AS names
+WHERE normalized_name <> ''
+ORDER BY normalized_name, name
+ON CONFLICT (normalized_name) DO NOTHING;
+
+INSERT INTO book_contributors (book_id, author_id, role, position)
+SELECT b.id, au.id, 'author', MIN(a.ord) - 1
+FROM books b
+CROSS JOIN LATERAL unnest(b.authors) WITH ORDINALITY AS a(name, ord)
+JOIN authors au ON au.normalized_name = lower(regexp_replace(a.name, '[^[:alnum:]]+', '', 'g'))
+GROUP BY b.id, au.id
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS book_genres;
+DROP TABLE IF EXISTS genre_aliases;
+DROP TABLE IF EXISTS genres;
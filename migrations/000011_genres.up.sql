@@ -0,0 +1,112 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id BIGSERIAL PRIMARY KEY,
+    parent_id BIGINT REFERENCES genres(id) ON DELETE RESTRICT,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS genres_parent_id_idx ON genres (parent_id);
+
+-- other spellings that should land on a genre, e.g. "sci-fi" and "sf"
+CREATE TABLE IF NOT EXISTS genre_aliases (
+    alias VARCHAR(100) PRIMARY KEY,
+    genre_id BIGINT NOT NULL REFERENCES genres(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS book_genres (
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    genre_id BIGINT NOT NULL REFERENCES genres(id) ON DELETE CASCADE,
+    PRIMARY KEY (book_id, genre_id)
+);
+
+CREATE INDEX IF NOT EXISTS book_genres_genre_id_idx ON book_genres (genre_id);
+
+-- starting taxonomy
+INSERT INTO genres (name, slug)
+VALUES ('Fiction', 'fiction'), ('Non-fiction', 'non-fiction')
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO genres (parent_id, name, slug)
+SELECT parent.id, v.name, v.slug
+FROM (VALUES
+    ('fiction', 'Science Fiction', 'science-fiction'),
+    ('fiction', 'Fantasy', 'fantasy'),
+    ('fiction', 'Mystery', 'mystery'),
+    ('fiction', 'Thriller', 'thriller'),
+    ('fiction', 'Romance', 'romance'),
+    ('fiction', 'Horror', 'horror'),
+    ('fiction', 'Historical Fiction', 'historical-fiction'),
+    ('fiction', 'Literary Fiction', 'literary-fiction'),
+    ('fiction', 'Young Adult', 'young-adult'),
+    ('non-fiction', 'Biography', 'biography'),
+    ('non-fiction', 'History', 'history'),
+    ('non-fiction', 'Science', 'science'),
+    ('non-fiction', 'Self-help', 'self-help'),
+    ('non-fiction', 'Poetry', 'poetry')
+) AS v(parent_slug, name, slug)
+INNER JOIN genres parent ON parent.slug = v.parent_slug
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO genres (parent_id, name, slug)
+SELECT parent.id, v.name, v.slug
+FROM (VALUES
+    ('science-fiction', 'Space Opera', 'space-opera'),
+    ('science-fiction', 'Cyberpunk', 'cyberpunk'),
+    ('fantasy', 'Epic Fantasy', 'epic-fantasy'),
+    ('fantasy', 'Urban Fantasy', 'urban-fantasy'),
+    ('mystery', 'Crime', 'crime')
+) AS v(parent_slug, name, slug)
+INNER JOIN genres parent ON parent.slug = v.parent_slug
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO genre_aliases (alias, genre_id)
+SELECT v.alias, g.id
+FROM (VALUES
+    ('sci-fi', 'science-fiction'),
+    ('scifi', 'science-fiction'),
+    ('sf', 'science-fiction'),
+    ('nonfiction', 'non-fiction'),
+    ('non fiction', 'non-fiction'),
+    ('memoir', 'biography'),
+    ('autobiography', 'biography'),
+    ('detective', 'mystery'),
+    ('suspense', 'thriller'),
+    ('ya', 'young-adult'),
+    ('historical', 'historical-fiction')
+) AS v(alias, slug)
+INNER JOIN genres g ON g.slug = v.slug
+ON CONFLICT (alias) DO NOTHING;
+
+-- free-text values that match nothing become top-level genres of their own
+INSERT INTO genres (name, slug)
+SELECT DISTINCT ON (slug) name, slug
+FROM (
+    SELECT trim(genre) AS name,
+        trim(BOTH '-' FROM regexp_replace(lower(trim(genre)), '[^a-z0-9]+', '-', 'g')) AS slug
+    FROM books
+    WHERE genre IS NOT NULL
+) AS t
+WHERE slug <> ''
+    AND NOT EXISTS (SELECT 1 FROM genre_aliases WHERE alias = lower(t.name))
+    AND NOT EXISTS (SELECT 1 FROM genres g WHERE g.slug = t.slug OR lower(g.name) = lower(t.name))
+ORDER BY slug, name
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO book_genres (book_id, genre_id)
+SELECT book_id, genre_id
+FROM (
+    SELECT b.id AS book_id, (
+        SELECT genre_id FROM genre_aliases WHERE alias = lower(trim(b.genre))
+        UNION ALL
+        SELECT id FROM genres
+        WHERE lower(name) = lower(trim(b.genre))
+            OR slug = trim(BOTH '-' FROM regexp_replace(lower(trim(b.genre)), '[^a-z0-9]+', '-', 'g'))
+        LIMIT 1
+    ) AS genre_id
+    FROM books b
+    WHERE b.genre IS NOT NULL
+) AS matched
+WHERE genre_id IS NOT NULL
+ON CONFLICT DO NOTHING;
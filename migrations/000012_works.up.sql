@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS works (
+    id BIGSERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version INT NOT NULL DEFAULT 1
+);
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS work_id BIGINT REFERENCES works(id);
+
+-- every existing book starts out as the only edition of its own work
+ALTER TABLE works ADD COLUMN seed_book_id BIGINT;
+
+INSERT INTO works (title, seed_book_id)
+SELECT title, id FROM books WHERE work_id IS NULL;
+
+UPDATE books SET work_id = works.id
+FROM works
+WHERE works.seed_book_id = books.id;
+
+ALTER TABLE works DROP COLUMN seed_book_id;
+
+ALTER TABLE books ALTER COLUMN work_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS books_work_id_idx ON books (work_id);
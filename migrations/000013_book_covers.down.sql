@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS books_cover_key_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS cover_key;
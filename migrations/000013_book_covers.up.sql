@@ -0,0 +1,3 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS cover_key VARCHAR(80) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS books_cover_key_idx ON books (cover_key) WHERE cover_key <> '';
@@ -0,0 +1,16 @@
+DELETE FROM boo_reviews WHERE deleted_at IS NOT NULL;
+DELETE FROM lists_names WHERE deleted_at IS NOT NULL;
+DELETE FROM books WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS boo_reviews_deleted_at_idx;
+DROP INDEX IF EXISTS lists_names_deleted_at_idx;
+DROP INDEX IF EXISTS books_deleted_at_idx;
+DROP INDEX IF EXISTS books_isbn_live_idx;
+ALTER TABLE books ADD CONSTRAINT books_isbn_key UNIQUE (isbn);
+
+ALTER TABLE boo_reviews DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE boo_reviews DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE lists_names DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE lists_names DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE books DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE books DROP COLUMN IF EXISTS deleted_at;
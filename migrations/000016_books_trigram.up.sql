@@ -0,0 +1,6 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- GiST rather than GIN so the suggestions can be read in similarity order
+-- straight from the index
+CREATE INDEX IF NOT EXISTS books_title_trgm_idx ON books USING GIST (title gist_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS authors_name_trgm_idx ON authors USING GIST (name gist_trgm_ops);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS book_redirects;
+DROP INDEX IF EXISTS books_normalized_title_trgm_idx;
+DROP FUNCTION IF EXISTS author_overlap(text[], text[]);
+DROP FUNCTION IF EXISTS normalize_title(text);
@@ -0,0 +1,30 @@
+-- lower case words without punctuation or a leading article, so "The
+-- Hobbit" and "Hobbit, the" compare equal
+CREATE OR REPLACE FUNCTION normalize_title(title text) RETURNS text AS $$
+    SELECT regexp_replace(
+        trim(regexp_replace(lower(title), '[^[:alnum:]]+', ' ', 'g')),
+        '^(the|a|an) | (the|a|an)$', '', 'g')
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- the share of authors two books have in common, comparing names the way
+-- authors.normalized_name does
+CREATE OR REPLACE FUNCTION author_overlap(a text[], b text[]) RETURNS float8 AS $$
+    WITH x AS (SELECT DISTINCT lower(regexp_replace(n, '[^[:alnum:]]+', '', 'g')) AS n FROM unnest(a) n),
+         y AS (SELECT DISTINCT lower(regexp_replace(n, '[^[:alnum:]]+', '', 'g')) AS n FROM unnest(b) n)
+    SELECT coalesce(
+        (SELECT count(*) FROM x INNER JOIN y USING (n))::float8
+            / nullif((SELECT count(*) FROM (SELECT n FROM x UNION SELECT n FROM y) u), 0),
+        0)
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE INDEX IF NOT EXISTS books_normalized_title_trgm_idx ON books USING GIST (normalize_title(title) gist_trgm_ops) WHERE deleted_at IS NULL;
+
+-- ids of books merged into another one, so links to them keep working
+CREATE TABLE IF NOT EXISTS book_redirects (
+    old_id INT PRIMARY KEY,
+    book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    merged_by INT REFERENCES users(id) ON DELETE SET NULL,
+    merged_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS book_redirects_book_id_idx ON book_redirects (book_id);
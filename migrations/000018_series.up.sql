@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS series (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version INT NOT NULL DEFAULT 1
+);
+
+-- position is fractional so a novella can sit at 2.5 between two novels
+CREATE TABLE IF NOT EXISTS book_series (
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    series_id BIGINT NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+    position NUMERIC(6,2) NOT NULL CHECK (position > 0),
+    PRIMARY KEY (book_id, series_id)
+);
+
+CREATE INDEX IF NOT EXISTS book_series_series_id_idx ON book_series (series_id, position);
@@ -0,0 +1,42 @@
+-- every edit of a book, with the state it left the book in. changed lists
+-- the fields that differ from the previous revision.
+CREATE TABLE IF NOT EXISTS book_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    editor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    snapshot JSONB NOT NULL,
+    changed TEXT[] NOT NULL DEFAULT '{}',
+    reverted_from INT,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (book_id, revision)
+);
+
+-- existing books start their history with their current state, in the
+-- shape written by BookState
+INSERT INTO book_revisions (book_id, revision, snapshot, changed)
+SELECT b.id, 1,
+    jsonb_build_object(
+        'title', b.title,
+        'authors', to_jsonb(coalesce(b.authors, '{}')),
+        'isbn', b.isbn,
+        'publication_date', coalesce(to_char(b.publication_date, 'YYYY-MM-DD'), '0001-01-01'),
+        'genre', coalesce(b.genre, ''),
+        'description', coalesce(b.description, ''),
+        'average_rating', coalesce(b.average_rating, 0),
+        'contributors', coalesce((
+            SELECT jsonb_agg(jsonb_build_object('name', a.name, 'role', c.role) ORDER BY c.role, c.position)
+            FROM book_contributors c
+            INNER JOIN authors a ON a.id = c.author_id
+            WHERE c.book_id = b.id AND c.role <> 'author'
+        ), '[]'),
+        'genres', coalesce((
+            SELECT jsonb_agg(g.slug ORDER BY g.slug)
+            FROM book_genres bg
+            INNER JOIN genres g ON g.id = bg.genre_id
+            WHERE bg.book_id = b.id
+        ), '[]')
+    ),
+    ARRAY['title', 'authors', 'isbn', 'publication_date', 'genre', 'description', 'average_rating', 'contributors', 'genres']
+FROM books b
+ON CONFLICT DO NOTHING;
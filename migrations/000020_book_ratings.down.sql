@@ -0,0 +1,3 @@
+ALTER TABLE books
+    DROP COLUMN IF EXISTS rating_histogram,
+    DROP COLUMN IF EXISTS review_count;
@@ -0,0 +1,25 @@
+-- rating_histogram counts the 1 to 5 star reviews, one element per star
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS review_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_histogram INT[] NOT NULL DEFAULT '{0,0,0,0,0}';
+
+UPDATE books
+SET average_rating = coalesce(s.average, 0),
+    review_count = s.total,
+    rating_histogram = s.histogram
+FROM (
+    SELECT b.id,
+        round(avg(r.rating), 2) AS average,
+        count(r.id) AS total,
+        ARRAY[
+            count(r.id) FILTER (WHERE r.rating = 1),
+            count(r.id) FILTER (WHERE r.rating = 2),
+            count(r.id) FILTER (WHERE r.rating = 3),
+            count(r.id) FILTER (WHERE r.rating = 4),
+            count(r.id) FILTER (WHERE r.rating = 5)
+        ]::int[] AS histogram
+    FROM books b
+    LEFT JOIN boo_reviews r ON r.book_id = b.id AND r.deleted_at IS NULL
+    GROUP BY b.id
+) s
+WHERE books.id = s.id;
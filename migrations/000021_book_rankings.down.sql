@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS book_rankings;
+DROP INDEX IF EXISTS boo_reviews_created_at_idx;
+DROP INDEX IF EXISTS book_lists_added_at_idx;
+ALTER TABLE book_lists DROP COLUMN IF EXISTS added_at;
@@ -0,0 +1,19 @@
+-- entries added before this migration have no added_at and never count
+-- towards trending
+ALTER TABLE book_lists ADD COLUMN IF NOT EXISTS added_at TIMESTAMP(0) WITH TIME ZONE;
+ALTER TABLE book_lists ALTER COLUMN added_at SET DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS book_lists_added_at_idx ON book_lists (added_at);
+CREATE INDEX IF NOT EXISTS boo_reviews_created_at_idx ON boo_reviews (created_at);
+
+-- precomputed scores, rebuilt periodically by the API server
+CREATE TABLE IF NOT EXISTS book_rankings (
+    ranking TEXT NOT NULL,
+    time_window TEXT NOT NULL,
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    computed_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (ranking, time_window, book_id)
+);
+
+CREATE INDEX IF NOT EXISTS book_rankings_score_idx ON book_rankings (ranking, time_window, score DESC);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS lists_names_created_by_idx;
+DROP TABLE IF EXISTS book_similarity;
@@ -0,0 +1,12 @@
+-- item to item similarity from readers who liked or listed both books,
+-- rebuilt periodically by the API server
+CREATE TABLE IF NOT EXISTS book_similarity (
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    similar_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    shared INT NOT NULL,
+    PRIMARY KEY (book_id, similar_id)
+);
+
+CREATE INDEX IF NOT EXISTS book_similarity_score_idx ON book_similarity (book_id, score DESC);
+CREATE INDEX IF NOT EXISTS lists_names_created_by_idx ON lists_names (created_by);
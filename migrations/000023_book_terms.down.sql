@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS book_terms;
+DROP FUNCTION IF EXISTS book_term_weights(text, text[], text, text);
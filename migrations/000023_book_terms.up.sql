@@ -0,0 +1,29 @@
+-- the stemmed terms of a book's title, authors, genre and description with
+-- how often each occurs, a title or author word counting more than one in
+-- the description
+CREATE OR REPLACE FUNCTION book_term_weights(title text, authors text[], genre text, description text)
+RETURNS TABLE (term text, weight float8) AS $$
+    SELECT t.lexeme, sum(cardinality(t.positions) * f.w)::float8
+    FROM (VALUES
+        (to_tsvector('english', coalesce(title, '')), 3),
+        (to_tsvector('simple', coalesce(array_to_string(authors, ' '), '')), 2),
+        (to_tsvector('english', coalesce(genre, '')), 2),
+        (to_tsvector('english', coalesce(description, '')), 1)
+    ) AS f(doc, w), unnest(f.doc) AS t
+    GROUP BY t.lexeme
+$$ LANGUAGE sql IMMUTABLE;
+
+-- term weights of every book for content similarity, kept up to date as
+-- books are added and edited
+CREATE TABLE IF NOT EXISTS book_terms (
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    term TEXT NOT NULL,
+    weight DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (book_id, term)
+);
+
+CREATE INDEX IF NOT EXISTS book_terms_term_idx ON book_terms (term);
+
+INSERT INTO book_terms (book_id, term, weight)
+SELECT books.id, w.term, w.weight
+FROM books, book_term_weights(books.title, books.authors, books.genre, books.description) w;
@@ -0,0 +1,11 @@
+-- members' own labels on books, kept apart from the reading list status
+CREATE TABLE IF NOT EXISTS book_tags (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    book_id BIGINT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, book_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS book_tags_tag_idx ON book_tags (tag, book_id);
+CREATE INDEX IF NOT EXISTS book_tags_book_id_idx ON book_tags (book_id);
@@ -0,0 +1,10 @@
+ALTER TABLE books
+    DROP CONSTRAINT IF EXISTS books_duration_audiobook_check,
+    DROP COLUMN IF EXISTS original_year,
+    DROP COLUMN IF EXISTS duration_minutes,
+    DROP COLUMN IF EXISTS format,
+    DROP COLUMN IF EXISTS pages,
+    DROP COLUMN IF EXISTS language,
+    DROP COLUMN IF EXISTS publisher_id;
+
+DROP TABLE IF EXISTS publishers;
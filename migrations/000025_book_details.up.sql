@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS publishers (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    -- lower case letters and digits without a trailing legal form, so
+    -- "Penguin Books Ltd." and "Penguin Books" are the same publisher
+    normalized_name VARCHAR(255) UNIQUE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- language is an ISO 639-1 code, duration_minutes is for audiobooks only
+ALTER TABLE books
+    ADD COLUMN IF NOT EXISTS publisher_id BIGINT REFERENCES publishers(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS language VARCHAR(2) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS pages INT CHECK (pages > 0),
+    ADD COLUMN IF NOT EXISTS format VARCHAR(20) NOT NULL DEFAULT ''
+        CHECK (format IN ('', 'hardcover', 'paperback', 'ebook', 'audiobook')),
+    ADD COLUMN IF NOT EXISTS duration_minutes INT CHECK (duration_minutes > 0),
+    ADD COLUMN IF NOT EXISTS original_year INT,
+    ADD CONSTRAINT books_duration_audiobook_check CHECK (duration_minutes IS NULL OR format = 'audiobook');
+
+CREATE INDEX IF NOT EXISTS books_publisher_id_idx ON books (publisher_id);
+CREATE INDEX IF NOT EXISTS books_language_idx ON books (language) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS books_pages_idx ON books (pages) WHERE deleted_at IS NULL;
+
+-- revisions recorded before these columns existed get them with the values
+-- every book started with, so the first later edit only shows real changes
+UPDATE book_revisions
+SET snapshot = jsonb_build_object(
+        'publisher', '',
+        'language', '',
+        'pages', NULL,
+        'format', '',
+        'duration_minutes', NULL,
+        'original_year', NULL
+    ) || snapshot
+WHERE NOT snapshot ? 'language';
@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE code = 'books:moderate';
+DROP TABLE IF EXISTS book_submissions;
@@ -0,0 +1,22 @@
+-- books sent in by members without catalog rights wait here until a
+-- moderator approves or rejects them
+CREATE TABLE IF NOT EXISTS book_submissions (
+    id BIGSERIAL PRIMARY KEY,
+    submitted_by INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    work_id BIGINT,
+    book jsonb NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    book_id INT REFERENCES books(id) ON DELETE SET NULL,
+    moderated_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    moderated_at TIMESTAMP(0) WITH TIME ZONE,
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS book_submissions_pending_idx ON book_submissions (created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS book_submissions_submitted_by_idx ON book_submissions (submitted_by);
+
+INSERT INTO permissions (code)
+VALUES ('books:moderate')
+ON CONFLICT (code) DO NOTHING;